@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"generator_boilerplate/config"
+	"generator_boilerplate/dataset"
+	"generator_boilerplate/server"
+	"generator_boilerplate/store"
+	"generator_boilerplate/types"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commands 是所有子命令, 没有指定子命令时运行 serve
+var commands = map[string]func(args []string) error{
+	"serve":    serve,
+	"accounts": generateAccounts,
+	"txs":      generateTransactions,
+	"replay":   replayDataset,
+}
+
+func serve(args []string) error {
+	cfg, err := config.ParseCommand("serve", args, nil)
+	if err != nil {
+		return err
+	}
+	var st store.Store = store.NewMemoryStore()
+	if cfg.StateDir != "" {
+		fileStore, err := store.NewFileStore(cfg.StateDir)
+		if err != nil {
+			return fmt.Errorf("open state directory: %w", err)
+		}
+		st = fileStore
+	}
+	return server.NewServer(cfg, st).Start()
+}
+
+// datasetFlags 是 accounts 与 txs 共用的参数
+type datasetFlags struct {
+	out      string
+	format   string
+	shardIDs string
+}
+
+func (d *datasetFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&d.out, "out", d.out, "directory to write the dataset to")
+	fs.StringVar(&d.format, "format", d.format, "dataset format: jsonl or binary")
+	fs.StringVar(&d.shardIDs, "shard-ids", d.shardIDs, "comma separated shards to generate for, empty means every shard of the topology")
+}
+
+// shards 返回要生成的 shard, 缺省为 topology 中的所有 shard
+func (d *datasetFlags) shards(topology []int) ([]int, error) {
+	if d.shardIDs == "" {
+		return topology, nil
+	}
+	known := make(map[int]bool, len(topology))
+	for _, id := range topology {
+		known[id] = true
+	}
+	ids := make([]int, 0)
+	for _, field := range strings.Split(d.shardIDs, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("invalid shard id %q", field)
+		}
+		if !known[id] {
+			return nil, fmt.Errorf("shard %d is not part of the topology", id)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// generateAccounts 为 -shards 中的每个 shard 生成账户并写入 <out>/accounts_shard_<id>
+func generateAccounts(args []string) error {
+	flags := datasetFlags{format: string(dataset.JSONL)}
+	number := 100
+	cfg, err := config.ParseCommand("accounts", args, func(fs *flag.FlagSet) {
+		flags.register(fs)
+		fs.IntVar(&number, "acc-number", number, "accounts to generate per shard")
+	})
+	if err != nil {
+		return err
+	}
+	format, err := dataset.ParseFormat(flags.format)
+	if err != nil {
+		return err
+	}
+	if flags.out == "" {
+		return errors.New("-out is required")
+	}
+	if number <= 0 {
+		return errors.New("-acc-number must be positive")
+	}
+	s := server.NewServer(cfg, store.NewMemoryStore())
+	topology := s.Shards.IDs()
+	shards, err := flags.shards(topology)
+	if err != nil {
+		return err
+	}
+
+	codec := types.Codec(cfg.Codec)
+	manifest := dataset.Manifest{
+		Format:      format,
+		Codec:       codec,
+		Shards:      topology,
+		Accounts:    make(map[int]int, len(shards)),
+		Partitioner: cfg.Partitioner,
+		Seed:        cfg.Seed,
+	}
+	for _, shardID := range shards {
+		accounts, err := s.GenerateAccounts(shardID, number, url.Values{})
+		if err != nil {
+			return fmt.Errorf("shard %d: %w", shardID, err)
+		}
+		if err := s.SetAccounts(shardID, accounts); err != nil {
+			log.Printf("[ERROR] Failed to build partitioner: %v", err)
+		}
+		records := make([][]byte, len(accounts))
+		for i := range accounts {
+			if records[i], err = codec.Encode(&accounts[i]); err != nil {
+				return err
+			}
+		}
+		if err := writeRecords(dataset.AccountsPath(flags.out, shardID, format), format, records); err != nil {
+			return err
+		}
+		manifest.Accounts[shardID] = len(accounts)
+		log.Printf("Wrote %d accounts for shard %d.", len(accounts), shardID)
+	}
+	return dataset.WriteManifest(flags.out, manifest)
+}
+
+// generateTransactions 从 -accounts 目录读取账户, 为每个 shard 生成 -batches 个批次并写入 <out>/txs_shard_<id>
+// NOTE: 批次在各个 shard 之间轮流生成, 跨片交易对影子账本的影响与在线生成时一致
+func generateTransactions(args []string) error {
+	flags := datasetFlags{}
+	accountsDir, batches := "", 10
+	cfg, err := config.ParseCommand("txs", args, func(fs *flag.FlagSet) {
+		flags.register(fs)
+		fs.StringVar(&accountsDir, "accounts", accountsDir, "dataset directory written by the accounts command, defaults to -out")
+		fs.IntVar(&batches, "batches", batches, "batches to generate per shard")
+	})
+	if err != nil {
+		return err
+	}
+	if flags.out == "" {
+		return errors.New("-out is required")
+	}
+	if accountsDir == "" {
+		accountsDir = flags.out
+	}
+	if batches <= 0 {
+		return errors.New("-batches must be positive")
+	}
+	manifest, err := dataset.ReadManifest(accountsDir)
+	if err != nil {
+		return fmt.Errorf("read accounts manifest: %w", err)
+	}
+	accountsFormat, format := manifest.Format, manifest.Format
+	if flags.format != "" {
+		if format, err = dataset.ParseFormat(flags.format); err != nil {
+			return err
+		}
+	}
+	accountsCodec, err := types.ParseCodec(string(manifest.Codec))
+	if err != nil {
+		return err
+	}
+	codec := types.Codec(cfg.Codec)
+	// NOTE: 拓扑以账户数据集为准, 已知的 leader 地址保留下来
+	table := make(map[string]string, len(manifest.Shards))
+	for _, id := range manifest.Shards {
+		name := fmt.Sprintf("Shard_%d", id)
+		table[name] = cfg.ShardsTable[name]
+	}
+	cfg.ShardsTable = table
+	s := server.NewServer(cfg, store.NewMemoryStore())
+	ids := make([]int, 0, len(manifest.Accounts))
+	for id := range manifest.Accounts {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, shardID := range ids {
+		accounts, err := readAccounts(dataset.AccountsPath(accountsDir, shardID, accountsFormat), accountsFormat, accountsCodec)
+		if err != nil {
+			return err
+		}
+		if err := s.SetAccounts(shardID, accounts); err != nil {
+			log.Printf("[ERROR] Failed to build partitioner: %v", err)
+		}
+	}
+	shards, err := flags.shards(ids)
+	if err != nil {
+		return err
+	}
+
+	generators := make([]*server.BatchGenerator, len(shards))
+	writers := make([]*dataset.Writer, len(shards))
+	defer func() {
+		for _, w := range writers {
+			if w != nil {
+				w.Close()
+			}
+		}
+	}()
+	for i, shardID := range shards {
+		if generators[i], err = s.NewBatchGenerator(shardID, url.Values{}); err != nil {
+			return err
+		}
+		if writers[i], err = dataset.Create(dataset.BatchesPath(flags.out, shardID, format), format); err != nil {
+			return err
+		}
+	}
+	transactions := make([]int, len(shards))
+	for batch := 0; batch < batches; batch++ {
+		for i, shardID := range shards {
+			msg, err := generators[i].Next()
+			if err != nil {
+				return fmt.Errorf("shard %d batch %d: %w", shardID, batch, err)
+			}
+			record, err := json.Marshal(msg)
+			if err != nil {
+				return err
+			}
+			if err := writers[i].Write(record); err != nil {
+				return err
+			}
+			transactions[i] += msg.TransactionNumber
+		}
+	}
+
+	manifest.Format, manifest.Codec = format, codec
+	manifest.Batches = make(map[int]int, len(shards))
+	manifest.Interval = cfg.GenerationInterval.String()
+	for i, shardID := range shards {
+		if err := writers[i].Close(); err != nil {
+			return err
+		}
+		manifest.Batches[shardID] = writers[i].Count()
+		status := generators[i].Status()
+		log.Printf("Wrote %d batches (%d transactions, %d partial) for shard %d.", writers[i].Count(), transactions[i], status.PartialBatches, shardID)
+		writers[i] = nil
+	}
+	// NOTE: 输出目录与账户目录不同时, 一并写入账户文件, 使输出目录可以单独回放
+	// 账户使用原始记录 (生成前的状态), 编码方式不同时重新编码
+	if accountsDir != flags.out || format != accountsFormat || codec != accountsCodec {
+		for _, shardID := range ids {
+			accounts, err := dataset.ReadAll(dataset.AccountsPath(accountsDir, shardID, accountsFormat), accountsFormat)
+			if err != nil {
+				return err
+			}
+			if accounts, err = recodeAccounts(accounts, accountsCodec, codec); err != nil {
+				return err
+			}
+			if err := writeRecords(dataset.AccountsPath(flags.out, shardID, format), format, accounts); err != nil {
+				return err
+			}
+		}
+	}
+	return dataset.WriteManifest(flags.out, manifest)
+}
+
+// replayDataset 启动 HTTP 服务 (接收回执并暴露指标), 把数据集回放给 -shards 中的 shard,
+// 结束后等待 -linger 再输出 job 状态与延迟统计
+func replayDataset(args []string) error {
+	dir, rate, speedup, sendAccounts, linger, listen := "", 0.0, 1.0, true, time.Duration(0), true
+	cfg, err := config.ParseCommand("replay", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&dir, "dataset", dir, "dataset directory written by the accounts and txs commands")
+		fs.Float64Var(&rate, "rate", rate, "batches per second per shard, 0 replays batches at their recorded timestamps")
+		fs.Float64Var(&speedup, "speedup", speedup, "divides the gaps between recorded timestamps")
+		fs.BoolVar(&sendAccounts, "send-accounts", sendAccounts, "send the accounts of the dataset to the shards before the batches")
+		fs.DurationVar(&linger, "linger", linger, "how long to keep collecting receipts after the last batch")
+		fs.BoolVar(&listen, "listen", listen, "serve the generator API on -port so that shards can report receipts during the replay")
+	})
+	if err != nil {
+		return err
+	}
+	if dir == "" {
+		return errors.New("-dataset is required")
+	}
+	replay, err := server.LoadDataset(dir, rate, speedup)
+	if err != nil {
+		return err
+	}
+	replay.SendAccounts = sendAccounts
+	s := server.NewServer(cfg, store.NewMemoryStore())
+	if listen {
+		// NOTE: 同步监听, 端口被占用 (例如默认端口与某个 shard 相同) 时直接返回错误
+		l, err := s.Listen()
+		if err != nil {
+			return fmt.Errorf("listen for receipts on port %s (set -port or -listen=false): %w", cfg.Port, err)
+		}
+		defer l.Close()
+		go func() {
+			if err := s.Serve(l); err != nil && !errors.Is(err, net.ErrClosed) {
+				log.Printf("[ERROR] Receipt listener stopped: %v", err)
+			}
+		}()
+	}
+	job, err := s.ReplayDataset(replay)
+	if err != nil {
+		return err
+	}
+	<-job.Done()
+	time.Sleep(linger)
+
+	report := struct {
+		Job     server.JobStatus     `json:"job"`
+		Latency server.LatencyReport `json:"latency"`
+	}{Job: job.Status(), Latency: s.Receipts.Report()}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+func writeRecords(path string, format dataset.Format, records [][]byte) error {
+	w, err := dataset.Create(path, format)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			w.Close()
+			return err
+		}
+	}
+	return w.Close()
+}
+
+func readAccounts(path string, format dataset.Format, codec types.Codec) ([]types.Account, error) {
+	records, err := dataset.ReadAll(path, format)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]types.Account, len(records))
+	for i, record := range records {
+		if err := codec.Decode(record, &accounts[i]); err != nil {
+			return nil, fmt.Errorf("%s: account %d: %w", path, i, err)
+		}
+	}
+	return accounts, nil
+}
+
+// recodeAccounts 将账户记录从编码 from 转换为编码 to
+func recodeAccounts(records [][]byte, from, to types.Codec) ([][]byte, error) {
+	if from == to {
+		return records, nil
+	}
+	recoded := make([][]byte, len(records))
+	for i, record := range records {
+		var acc types.Account
+		if err := from.Decode(record, &acc); err != nil {
+			return nil, fmt.Errorf("account %d: %w", i, err)
+		}
+		var err error
+		if recoded[i], err = to.Encode(&acc); err != nil {
+			return nil, fmt.Errorf("account %d: %w", i, err)
+		}
+	}
+	return recoded, nil
+}
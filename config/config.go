@@ -0,0 +1,455 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"generator_boilerplate/constant"
+	"generator_boilerplate/generator"
+	"generator_boilerplate/types"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix 是环境变量覆盖配置时使用的前缀, 例如 GENERATOR_PORT
+const EnvPrefix = "GENERATOR_"
+
+// Config 描述一次实验中生成器的全部参数, 默认值来自 constant 包
+// NOTE: 优先级为 默认值 < 配置文件 < 环境变量 < 命令行参数
+type Config struct {
+	Port                       string   `json:"port"`
+	Balance                    int64    `json:"balance"`
+	TransactionsGeneration     int      `json:"transactions_generation"`
+	OverloadTransactionsRatio  float64  `json:"overload_transactions_ratio"`
+	CrossShardTransactionRatio int      `json:"cross_shard_transaction_ratio"`
+	MaxTxsInBlock              int      `json:"max_txs_in_block"`
+	GenerationInterval         Duration `json:"generation_interval"`
+	// NOTE: 每个批次最多尝试 number * attempts_per_tx 次生成, 批次大小超出账户能力时的处理方式 partial / error
+	AttemptsPerTx   int    `json:"attempts_per_tx"`
+	InfeasibleBatch string `json:"infeasible_batch"`
+	// NOTE: 账户选择分布 uniform / zipf / hotspot
+	AccountDistribution string  `json:"account_distribution"`
+	ZipfTheta           float64 `json:"zipf_theta"`
+	HotspotTxRatio      float64 `json:"hotspot_tx_ratio"`
+	HotspotAccountRatio float64 `json:"hotspot_account_ratio"`
+	// NOTE: 转账金额分布 constant / uniform / lognormal / histogram
+	ValueDistribution string  `json:"value_distribution"`
+	Value             int64   `json:"value"`
+	ValueMin          int64   `json:"value_min"`
+	ValueMax          int64   `json:"value_max"`
+	ValueMu           float64 `json:"value_mu"`
+	ValueSigma        float64 `json:"value_sigma"`
+	ValueHistogram    string  `json:"value_histogram"`
+	// NOTE: 是否在生成前从 shard 同步已提交的 nonce, 以及落后时的处理策略 keep / rewind
+	NonceSync      bool   `json:"nonce_sync"`
+	NonceGapPolicy string `json:"nonce_gap_policy"`
+	// NOTE: 地址到 shard 的划分策略 account / modulo / prefix / consistent / static
+	Partitioner   string `json:"partitioner"`
+	VirtualNodes  int    `json:"virtual_nodes"`
+	PartitionFile string `json:"partition_file"`
+	// NOTE: 发送给 shard 的请求的超时时间, 失败后的最大重试次数与初始退避时间 (每次重试翻倍)
+	RequestTimeout Duration `json:"request_timeout"`
+	MaxRetries     int      `json:"max_retries"`
+	RetryBackoff   Duration `json:"retry_backoff"`
+	// NOTE: 消息中每个账户或交易的编码方式 json / rlp / eth
+	Codec string `json:"codec"`
+	// NOTE: 交易格式 native / legacy (EIP-155) / dynamic (EIP-1559), 后两者生成签名后的以太坊交易, gas 价格以 wei 为单位
+	// 账本按照 gas_limit 乘以 gas 价格 (dynamic 为 gas_fee_cap) 扣除 gas 费用, balance 至少要够支付一笔转账
+	TxType      string            `json:"tx_type"`
+	ChainID     int64             `json:"chain_id"`
+	GasLimit    int64             `json:"gas_limit"`
+	GasPrice    int64             `json:"gas_price"`
+	GasTipCap   int64             `json:"gas_tip_cap"`
+	GasFeeCap   int64             `json:"gas_fee_cap"`
+	ShardsTable map[string]string `json:"shards_table"`
+	Seed        int64             `json:"seed"`
+	Mnemonic    string            `json:"mnemonic"`
+	StateDir    string            `json:"state_dir"`
+	// NOTE: 请求参数中的文件 (value_histogram, trace 与数据集回放的 path) 只能是该目录下的相对路径
+	DataDir string `json:"data_dir"`
+}
+
+// Duration 在 JSON 中使用 "10s" 这样的字符串表示
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(content []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(content, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case float64:
+		d.Duration = time.Duration(v)
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		d.Duration = parsed
+	default:
+		return fmt.Errorf("invalid duration %s", string(content))
+	}
+	return nil
+}
+
+func Default() *Config {
+	shardsTable := make(map[string]string, len(constant.ShardsTable))
+	for shard, url := range constant.ShardsTable {
+		shardsTable[shard] = url
+	}
+	return &Config{
+		Port:                       constant.Port,
+		Balance:                    constant.Balance,
+		TransactionsGeneration:     constant.TransactionsGeneration,
+		OverloadTransactionsRatio:  constant.OverloadTransactionsRatio,
+		CrossShardTransactionRatio: constant.CrossShardTransactionRatio,
+		MaxTxsInBlock:              constant.MaxTxsInBlock,
+		AttemptsPerTx:              constant.AttemptsPerTx,
+		InfeasibleBatch:            constant.InfeasibleBatch,
+		GenerationInterval:         Duration{constant.GenerationInterval},
+		AccountDistribution:        constant.AccountDistribution,
+		ZipfTheta:                  constant.ZipfTheta,
+		HotspotTxRatio:             constant.HotspotTxRatio,
+		HotspotAccountRatio:        constant.HotspotAccountRatio,
+		NonceGapPolicy:             constant.NonceGapPolicy,
+		ValueDistribution:          constant.ValueDistribution,
+		Value:                      constant.Value,
+		ValueMin:                   constant.ValueMin,
+		ValueMax:                   constant.ValueMax,
+		ValueSigma:                 constant.ValueSigma,
+		Partitioner:                constant.Partitioner,
+		VirtualNodes:               constant.VirtualNodes,
+		RequestTimeout:             Duration{constant.RequestTimeout},
+		MaxRetries:                 constant.MaxRetries,
+		RetryBackoff:               Duration{constant.RetryBackoff},
+		Codec:                      constant.Codec,
+		TxType:                     constant.TxType,
+		ChainID:                    constant.ChainID,
+		GasLimit:                   constant.GasLimit,
+		GasPrice:                   constant.GasPrice,
+		GasTipCap:                  constant.GasTipCap,
+		GasFeeCap:                  constant.GasFeeCap,
+		ShardsTable:                shardsTable,
+		DataDir:                    constant.DataDir,
+	}
+}
+
+// Load 读取配置文件, 扩展名为 .yaml / .yml 时按 YAML 解析, 否则按 JSON 解析, 文件中缺省的字段保持默认值
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		content, err = yamlToJSON(content)
+		if err != nil {
+			return nil, fmt.Errorf("parse config %s: %w", path, err)
+		}
+	}
+	if err := json.Unmarshal(content, cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// yamlToJSON 将 YAML 文档转换为 JSON, 使 YAML 与 JSON 配置共用字段名 (json tag) 与 Duration 的解析
+func yamlToJSON(content []byte) ([]byte, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(doc)
+}
+
+// Parse 依次应用配置文件 (-config), 环境变量和命令行参数
+func Parse(args []string) (*Config, error) {
+	return ParseCommand("generator", args, nil)
+}
+
+// ParseCommand 与 Parse 相同, register 可以为子命令注册额外的命令行参数
+func ParseCommand(name string, args []string, register func(fs *flag.FlagSet)) (*Config, error) {
+	// NOTE: 第一遍只为了拿到 -config, 第二遍在文件和环境变量之上应用命令行参数
+	path := ""
+	pre := newFlagSet(name, Default(), &path)
+	if register != nil {
+		register(pre)
+	}
+	pre.SetOutput(io.Discard)
+	// NOTE: 解析错误会在第二遍中重新报告
+	_ = pre.Parse(args)
+
+	cfg := Default()
+	if path != "" {
+		var err error
+		if cfg, err = Load(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.ApplyEnv(); err != nil {
+		return nil, err
+	}
+	fs := newFlagSet(name, cfg, &path)
+	if register != nil {
+		register(fs)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func newFlagSet(name string, cfg *Config, path *string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(path, "config", *path, "path to a JSON or YAML (.yaml, .yml) config file")
+	fs.StringVar(&cfg.Port, "port", cfg.Port, "port to listen on")
+	fs.Int64Var(&cfg.Balance, "balance", cfg.Balance, "initial balance of generated accounts")
+	fs.IntVar(&cfg.TransactionsGeneration, "transactions", cfg.TransactionsGeneration, "transactions per batch")
+	fs.Float64Var(&cfg.OverloadTransactionsRatio, "overload-ratio", cfg.OverloadTransactionsRatio, "extra transactions ratio for overloaded shards")
+	fs.IntVar(&cfg.CrossShardTransactionRatio, "cross-shard-ratio", cfg.CrossShardTransactionRatio, "percentage of cross shard transactions")
+	fs.IntVar(&cfg.MaxTxsInBlock, "max-txs", cfg.MaxTxsInBlock, "max transactions per sender in a batch")
+	fs.IntVar(&cfg.AttemptsPerTx, "attempts-per-tx", cfg.AttemptsPerTx, "generation attempts per requested transaction before a batch is cut short")
+	fs.StringVar(&cfg.InfeasibleBatch, "infeasible-batch", cfg.InfeasibleBatch, "batch size beyond what the accounts allow: partial sends what is possible, error sends nothing")
+	fs.DurationVar(&cfg.GenerationInterval.Duration, "interval", cfg.GenerationInterval.Duration, "interval between batches")
+	fs.StringVar(&cfg.AccountDistribution, "distribution", cfg.AccountDistribution, "account selection distribution: uniform, zipf or hotspot")
+	fs.Float64Var(&cfg.ZipfTheta, "zipf-theta", cfg.ZipfTheta, "skew of the zipf distribution")
+	fs.Float64Var(&cfg.HotspotTxRatio, "hotspot-tx", cfg.HotspotTxRatio, "fraction of selections hitting hot accounts")
+	fs.Float64Var(&cfg.HotspotAccountRatio, "hotspot-accounts", cfg.HotspotAccountRatio, "fraction of accounts that are hot")
+	fs.StringVar(&cfg.ValueDistribution, "value-dist", cfg.ValueDistribution, "transfer value distribution: constant, uniform, lognormal or histogram")
+	fs.Int64Var(&cfg.Value, "value", cfg.Value, "transfer value of the constant distribution")
+	fs.Int64Var(&cfg.ValueMin, "value-min", cfg.ValueMin, "lower bound of the uniform value distribution")
+	fs.Int64Var(&cfg.ValueMax, "value-max", cfg.ValueMax, "upper bound of the uniform value distribution")
+	fs.Float64Var(&cfg.ValueMu, "value-mu", cfg.ValueMu, "mu of the lognormal value distribution")
+	fs.Float64Var(&cfg.ValueSigma, "value-sigma", cfg.ValueSigma, "sigma of the lognormal value distribution")
+	fs.StringVar(&cfg.ValueHistogram, "value-histogram", cfg.ValueHistogram, "CSV file of value,weight or min,max,weight rows")
+	fs.BoolVar(&cfg.NonceSync, "nonce-sync", cfg.NonceSync, "reconcile nonces with the committed nonces of shards before generating")
+	fs.StringVar(&cfg.NonceGapPolicy, "nonce-gap-policy", cfg.NonceGapPolicy, "how to handle committed nonces behind the generator: keep or rewind")
+	fs.StringVar(&cfg.Partitioner, "partitioner", cfg.Partitioner, "address to shard partitioning: account, modulo, prefix, consistent or static")
+	fs.IntVar(&cfg.VirtualNodes, "virtual-nodes", cfg.VirtualNodes, "virtual nodes per shard of the consistent hashing partitioner")
+	fs.StringVar(&cfg.PartitionFile, "partition-file", cfg.PartitionFile, "JSON or CSV file mapping addresses to shards for the static partitioner")
+	fs.DurationVar(&cfg.RequestTimeout.Duration, "request-timeout", cfg.RequestTimeout.Duration, "timeout of requests sent to shards")
+	fs.IntVar(&cfg.MaxRetries, "max-retries", cfg.MaxRetries, "retries of a failed request to a shard before it is dead-lettered")
+	fs.DurationVar(&cfg.RetryBackoff.Duration, "retry-backoff", cfg.RetryBackoff.Duration, "backoff before the first retry, doubled on each further retry")
+	fs.StringVar(&cfg.Codec, "codec", cfg.Codec, "encoding of the accounts and transactions inside messages: json, rlp or eth (raw signed ethereum transactions)")
+	fs.StringVar(&cfg.TxType, "tx-type", cfg.TxType, "transaction format: native, legacy (EIP-155) or dynamic (EIP-1559)")
+	fs.Int64Var(&cfg.ChainID, "chain-id", cfg.ChainID, "chain id of ethereum transactions")
+	fs.Int64Var(&cfg.GasLimit, "gas-limit", cfg.GasLimit, "gas limit of ethereum transactions")
+	fs.Int64Var(&cfg.GasPrice, "gas-price", cfg.GasPrice, "gas price in wei of legacy transactions")
+	fs.Int64Var(&cfg.GasTipCap, "gas-tip-cap", cfg.GasTipCap, "max priority fee per gas in wei of EIP-1559 transactions")
+	fs.Int64Var(&cfg.GasFeeCap, "gas-fee-cap", cfg.GasFeeCap, "max fee per gas in wei of EIP-1559 transactions")
+	fs.Var((*shardsTableValue)(&cfg.ShardsTable), "shards", "shard table as Shard_0=http://host:port,Shard_1=...")
+	fs.Int64Var(&cfg.Seed, "seed", cfg.Seed, "random seed for reproducible generation, 0 uses crypto/rand")
+	fs.StringVar(&cfg.Mnemonic, "mnemonic", cfg.Mnemonic, "BIP-39 mnemonic to derive accounts from along m/44'/60'/shard'/0/i")
+	fs.StringVar(&cfg.StateDir, "state-dir", cfg.StateDir, "directory to persist account state in, empty keeps state in memory only")
+	fs.StringVar(&cfg.DataDir, "data-dir", cfg.DataDir, "directory that files named in request parameters are resolved under")
+	return fs
+}
+
+// ApplyEnv 使用 GENERATOR_ 前缀的环境变量覆盖配置, 例如 GENERATOR_MAX_TXS_IN_BLOCK=50
+func (c *Config) ApplyEnv() error {
+	fs := newFlagSet("generator", c, new(string))
+	envNames := map[string]string{
+		"port":              "PORT",
+		"balance":           "BALANCE",
+		"transactions":      "TRANSACTIONS_GENERATION",
+		"overload-ratio":    "OVERLOAD_TRANSACTIONS_RATIO",
+		"cross-shard-ratio": "CROSS_SHARD_TRANSACTION_RATIO",
+		"max-txs":           "MAX_TXS_IN_BLOCK",
+		"attempts-per-tx":   "ATTEMPTS_PER_TX",
+		"infeasible-batch":  "INFEASIBLE_BATCH",
+		"interval":          "GENERATION_INTERVAL",
+		"distribution":      "ACCOUNT_DISTRIBUTION",
+		"zipf-theta":        "ZIPF_THETA",
+		"hotspot-tx":        "HOTSPOT_TX_RATIO",
+		"hotspot-accounts":  "HOTSPOT_ACCOUNT_RATIO",
+		"value-dist":        "VALUE_DISTRIBUTION",
+		"value":             "VALUE",
+		"value-min":         "VALUE_MIN",
+		"value-max":         "VALUE_MAX",
+		"value-mu":          "VALUE_MU",
+		"value-sigma":       "VALUE_SIGMA",
+		"value-histogram":   "VALUE_HISTOGRAM",
+		"nonce-sync":        "NONCE_SYNC",
+		"nonce-gap-policy":  "NONCE_GAP_POLICY",
+		"partitioner":       "PARTITIONER",
+		"virtual-nodes":     "VIRTUAL_NODES",
+		"partition-file":    "PARTITION_FILE",
+		"request-timeout":   "REQUEST_TIMEOUT",
+		"max-retries":       "MAX_RETRIES",
+		"retry-backoff":     "RETRY_BACKOFF",
+		"codec":             "CODEC",
+		"tx-type":           "TX_TYPE",
+		"chain-id":          "CHAIN_ID",
+		"gas-limit":         "GAS_LIMIT",
+		"gas-price":         "GAS_PRICE",
+		"gas-tip-cap":       "GAS_TIP_CAP",
+		"gas-fee-cap":       "GAS_FEE_CAP",
+		"shards":            "SHARDS_TABLE",
+		"seed":              "SEED",
+		"mnemonic":          "MNEMONIC",
+		"state-dir":         "STATE_DIR",
+		"data-dir":          "DATA_DIR",
+	}
+	for name, env := range envNames {
+		value, ok := os.LookupEnv(EnvPrefix + env)
+		if !ok {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("%s%s: %w", EnvPrefix, env, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) Validate() error {
+	if err := c.validateNames(); err != nil {
+		return err
+	}
+	switch {
+	case c.Port == "":
+		return errors.New("port must not be empty")
+	case c.TransactionsGeneration <= 0:
+		return errors.New("transactions_generation must be positive")
+	case c.OverloadTransactionsRatio < 0:
+		return errors.New("overload_transactions_ratio must not be negative")
+	case c.CrossShardTransactionRatio < 0 || c.CrossShardTransactionRatio > 100:
+		return errors.New("cross_shard_transaction_ratio must be within [0, 100]")
+	case c.MaxTxsInBlock <= 0:
+		return errors.New("max_txs_in_block must be positive")
+	case c.GenerationInterval.Duration <= 0:
+		return errors.New("generation_interval must be positive")
+	case c.AttemptsPerTx <= 0:
+		return errors.New("attempts_per_tx must be positive")
+	case c.ZipfTheta < 0:
+		return errors.New("zipf_theta must not be negative")
+	case c.VirtualNodes <= 0:
+		return errors.New("virtual_nodes must be positive")
+	case c.DataDir == "":
+		return errors.New("data_dir must not be empty")
+	case c.RequestTimeout.Duration <= 0:
+		return errors.New("request_timeout must be positive")
+	case c.MaxRetries < 0:
+		return errors.New("max_retries must not be negative")
+	case c.RetryBackoff.Duration < 0:
+		return errors.New("retry_backoff must not be negative")
+	case types.Codec(c.Codec) == types.CodecEthereum && types.TxType(c.TxType) == types.TxNative:
+		return errors.New("codec eth requires tx_type legacy or dynamic")
+	case c.ChainID <= 0:
+		return errors.New("chain_id must be positive")
+	case c.GasLimit < 21000:
+		return errors.New("gas_limit must be at least 21000")
+	case c.GasPrice < 0 || c.GasTipCap < 0:
+		return errors.New("gas_price and gas_tip_cap must not be negative")
+	case c.GasFeeCap < c.GasTipCap:
+		return errors.New("gas_fee_cap must not be below gas_tip_cap")
+	case types.TxType(c.TxType) != types.TxNative && c.maxFee().Cmp(big.NewInt(c.Balance-1)) > 0:
+		return fmt.Errorf("balance %d cannot pay the max gas cost %s of a transfer", c.Balance, c.maxFee())
+	case c.Mnemonic != "" && generator.ValidateMnemonic(c.Mnemonic) != nil:
+		return fmt.Errorf("mnemonic: %w", generator.ValidateMnemonic(c.Mnemonic))
+	case c.HotspotTxRatio < 0 || c.HotspotTxRatio > 1 || c.HotspotAccountRatio <= 0 || c.HotspotAccountRatio > 1:
+		return errors.New("hotspot ratios must be within (0, 1]")
+	}
+	return nil
+}
+
+// validateNames 使用各个包自己的解析函数检查分布, 策略与编码的名称及其参数
+func (c *Config) validateNames() error {
+	if _, err := generator.ParseBatchPolicy(c.InfeasibleBatch); err != nil {
+		return fmt.Errorf("infeasible_batch: %w", err)
+	}
+	if _, err := generator.NewSelector(c.AccountDistribution, c.ZipfTheta, c.HotspotTxRatio, c.HotspotAccountRatio); err != nil {
+		return fmt.Errorf("account_distribution: %w", err)
+	}
+	values := generator.ValueSpec{
+		Distribution: c.ValueDistribution,
+		Constant:     c.Value,
+		Min:          c.ValueMin,
+		Max:          c.ValueMax,
+		Mu:           c.ValueMu,
+		Sigma:        c.ValueSigma,
+		Histogram:    c.ValueHistogram,
+	}
+	if _, err := values.Build(); err != nil {
+		return fmt.Errorf("value_distribution: %w", err)
+	}
+	if _, err := generator.ParseGapPolicy(c.NonceGapPolicy); err != nil {
+		return fmt.Errorf("nonce_gap_policy: %w", err)
+	}
+	if c.Partitioner == generator.PartitionStatic && c.PartitionFile == "" {
+		return errors.New("static partitioner requires partition_file")
+	}
+	partition := generator.PartitionSpec{Strategy: c.Partitioner, VirtualNodes: c.VirtualNodes, File: c.PartitionFile}
+	if _, err := partition.Build(nil, nil); err != nil {
+		return fmt.Errorf("partitioner: %w", err)
+	}
+	if _, err := types.ParseCodec(c.Codec); err != nil {
+		return fmt.Errorf("codec: %w", err)
+	}
+	if _, err := types.ParseTxType(c.TxType); err != nil {
+		return fmt.Errorf("tx_type: %w", err)
+	}
+	return nil
+}
+
+// maxFee 返回一笔以太坊交易最多支付的 gas 费用, 见 types.EthereumConfig.MaxFee
+func (c *Config) maxFee() *big.Int {
+	cfg := types.EthereumConfig{Type: types.TxType(c.TxType), GasLimit: uint64(c.GasLimit), GasPrice: big.NewInt(c.GasPrice), GasFeeCap: big.NewInt(c.GasFeeCap)}
+	return cfg.MaxFee()
+}
+
+// shardsTableValue 以 "Shard_0=url,Shard_1=url" 的形式解析 ShardsTable
+type shardsTableValue map[string]string
+
+func (v *shardsTableValue) String() string {
+	if v == nil {
+		return ""
+	}
+	entries := make([]string, 0, len(*v))
+	for shard, url := range *v {
+		entries = append(entries, shard+"="+url)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+func (v *shardsTableValue) Set(value string) error {
+	table := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		shard, url, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid shard entry %q", entry)
+		}
+		if _, err := strconv.Atoi(strings.TrimPrefix(shard, "Shard_")); err != nil {
+			return fmt.Errorf("invalid shard name %q", shard)
+		}
+		table[shard] = url
+	}
+	*v = table
+	return nil
+}
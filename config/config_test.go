@@ -0,0 +1,106 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParsePrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"port": "9000", "max_txs_in_block": 5, "generation_interval": "3s", "cross_shard_transaction_ratio": 40}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvPrefix+"MAX_TXS_IN_BLOCK", "7")
+	t.Setenv(EnvPrefix+"CROSS_SHARD_TRANSACTION_RATIO", "50")
+
+	cfg, err := Parse([]string{"-config", path, "-cross-shard-ratio", "60"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "9000" {
+		t.Errorf("port from file: have %s", cfg.Port)
+	}
+	if cfg.GenerationInterval.Duration != 3*time.Second {
+		t.Errorf("interval from file: have %s", cfg.GenerationInterval)
+	}
+	if cfg.MaxTxsInBlock != 7 {
+		t.Errorf("max txs from env: have %d", cfg.MaxTxsInBlock)
+	}
+	if cfg.CrossShardTransactionRatio != 60 {
+		t.Errorf("cross shard ratio from flag: have %d", cfg.CrossShardTransactionRatio)
+	}
+	if cfg.TransactionsGeneration != Default().TransactionsGeneration {
+		t.Errorf("transactions should keep default: have %d", cfg.TransactionsGeneration)
+	}
+}
+
+func TestValidateGasCost(t *testing.T) {
+	cfg := Default()
+	cfg.TxType = "legacy"
+	// NOTE: 默认余额不足以支付 gas_limit * gas_price
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("balance below the max gas cost accepted")
+	}
+	cfg.Balance = cfg.GasLimit*cfg.GasPrice + 1
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	cfg.TxType = "dynamic"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("balance below gas_limit * gas_fee_cap accepted")
+	}
+}
+
+func TestLoadYAML(t *testing.T) {
+	dir := t.TempDir()
+	content := "port: \"9000\"\nmax_txs_in_block: 5\ngeneration_interval: 3s\nshards_table:\n  Shard_0: http://10.0.0.1:9200\n"
+	for _, name := range []string{"config.yaml", "config.yml"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Port != "9000" || cfg.MaxTxsInBlock != 5 || cfg.GenerationInterval.Duration != 3*time.Second || cfg.ShardsTable["Shard_0"] != "http://10.0.0.1:9200" {
+			t.Fatalf("%s loaded as %+v", name, cfg)
+		}
+		if cfg.TransactionsGeneration != Default().TransactionsGeneration {
+			t.Errorf("%s: transactions should keep default: have %d", name, cfg.TransactionsGeneration)
+		}
+	}
+	// NOTE: JSON 文件不会被当作 YAML 解析
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatal("YAML content in a .json file accepted")
+	}
+}
+
+func TestValidateNames(t *testing.T) {
+	for name, modify := range map[string]func(cfg *Config){
+		"infeasible_batch":     func(cfg *Config) { cfg.InfeasibleBatch = "drop" },
+		"account_distribution": func(cfg *Config) { cfg.AccountDistribution = "pareto" },
+		"value_distribution":   func(cfg *Config) { cfg.ValueDistribution = "normal" },
+		"value":                func(cfg *Config) { cfg.Value = 0 },
+		"nonce_gap_policy":     func(cfg *Config) { cfg.NonceGapPolicy = "skip" },
+		"partitioner":          func(cfg *Config) { cfg.Partitioner = "range" },
+		"codec":                func(cfg *Config) { cfg.Codec = "protobuf" },
+		"tx_type":              func(cfg *Config) { cfg.TxType = "blob" },
+	} {
+		cfg := Default()
+		modify(cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("invalid %s accepted", name)
+		}
+	}
+	if err := Default().Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,5 +1,7 @@
 package constant
 
+import "time"
+
 const (
 	Port                       = "8000"
 	Balance                    = 10000000
@@ -7,6 +9,33 @@ const (
 	OverloadTransactionsRatio  = 0.25
 	CrossShardTransactionRatio = 25
 	MaxTxsInBlock              = 20
+	AttemptsPerTx              = 20
+	InfeasibleBatch            = "partial"
+	GenerationInterval         = 10 * time.Second
+	AccountDistribution        = "uniform"
+	ZipfTheta                  = 0.99
+	HotspotTxRatio             = 0.8
+	HotspotAccountRatio        = 0.2
+	NonceGapPolicy             = "keep"
+	ValueDistribution          = "constant"
+	Value                      = 1
+	ValueMin                   = 1
+	ValueMax                   = 100
+	ValueSigma                 = 1.0
+	TraceBlockInterval         = 12 * time.Second
+	Partitioner                = "account"
+	VirtualNodes               = 100
+	RequestTimeout             = 5 * time.Second
+	MaxRetries                 = 3
+	RetryBackoff               = 200 * time.Millisecond
+	Codec                      = "json"
+	TxType                     = "native"
+	ChainID                    = 1337
+	GasLimit                   = 21000
+	GasPrice                   = 1000000000
+	GasTipCap                  = 1000000000
+	GasFeeCap                  = 2000000000
+	DataDir                    = "data"
 )
 
 var ShardsTable = map[string]string{
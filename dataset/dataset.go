@@ -0,0 +1,233 @@
+package dataset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"generator_boilerplate/types"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Format 是数据集文件的格式, 每条记录都是发送给 shard 的原始请求体
+type Format string
+
+const (
+	// NOTE: 每行一条 JSON 记录
+	JSONL Format = "jsonl"
+	// NOTE: 每条记录前是 uvarint 编码的长度, 记录中可以包含任意字节
+	Binary Format = "binary"
+)
+
+// NOTE: 单条记录的长度上限, 防止读到损坏的长度时分配过多内存
+const maxRecordSize = 256 << 20
+
+var ErrRecordTooLarge = errors.New("dataset record too large")
+
+func ParseFormat(format string) (Format, error) {
+	switch Format(format) {
+	case JSONL, Binary:
+		return Format(format), nil
+	}
+	return "", fmt.Errorf("unknown dataset format %q", format)
+}
+
+// Ext 返回该格式的文件扩展名
+func (f Format) Ext() string {
+	if f == Binary {
+		return ".bin"
+	}
+	return ".jsonl"
+}
+
+// AccountsPath 返回 shard 账户文件的路径, 每条记录是一个 types.Account
+func AccountsPath(dir string, shardID int, format Format) string {
+	return filepath.Join(dir, fmt.Sprintf("accounts_shard_%d%s", shardID, format.Ext()))
+}
+
+// BatchesPath 返回 shard 交易批次文件的路径, 每条记录是一个 types.RequestMsg
+func BatchesPath(dir string, shardID int, format Format) string {
+	return filepath.Join(dir, fmt.Sprintf("txs_shard_%d%s", shardID, format.Ext()))
+}
+
+// Writer 将记录依次写入文件
+type Writer struct {
+	format Format
+	file   *os.File
+	buf    *bufio.Writer
+	count  int
+}
+
+func Create(path string, format Format) (*Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{format: format, file: file, buf: bufio.NewWriter(file)}, nil
+}
+
+func (w *Writer) Write(record []byte) error {
+	switch w.format {
+	case Binary:
+		var header [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(header[:], uint64(len(record)))
+		if _, err := w.buf.Write(header[:n]); err != nil {
+			return err
+		}
+		if _, err := w.buf.Write(record); err != nil {
+			return err
+		}
+	default:
+		if bytes.IndexByte(record, '\n') >= 0 {
+			return errors.New("jsonl record must not contain a newline")
+		}
+		if _, err := w.buf.Write(record); err != nil {
+			return err
+		}
+		if err := w.buf.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	w.count++
+	return nil
+}
+
+// Count 返回已经写入的记录数
+func (w *Writer) Count() int {
+	return w.count
+}
+
+func (w *Writer) Close() error {
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// Reader 依次读取 Writer 写入的记录
+type Reader struct {
+	format Format
+	file   *os.File
+	buf    *bufio.Reader
+}
+
+func Open(path string, format Format) (*Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{format: format, file: file, buf: bufio.NewReader(file)}, nil
+}
+
+// Next 返回下一条记录, 读完时返回 io.EOF
+func (r *Reader) Next() ([]byte, error) {
+	if r.format == Binary {
+		size, err := binary.ReadUvarint(r.buf)
+		if err != nil {
+			return nil, err
+		}
+		if size > maxRecordSize {
+			return nil, ErrRecordTooLarge
+		}
+		record := make([]byte, size)
+		if _, err := io.ReadFull(r.buf, record); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return record, nil
+	}
+	for {
+		line, err := r.buf.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *Reader) Close() error {
+	return r.file.Close()
+}
+
+// ReadAll 读取文件中的所有记录
+func ReadAll(path string, format Format) ([][]byte, error) {
+	r, err := Open(path, format)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	records := make([][]byte, 0)
+	for {
+		record, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		records = append(records, record)
+	}
+}
+
+const manifestFile = "manifest.json"
+
+// Manifest 描述一个数据集目录: 格式, shard 拓扑以及每个 shard 的记录数
+type Manifest struct {
+	Format Format `json:"format"`
+	// NOTE: 账户记录的编码方式, 缺省为 json; 批次中交易的编码方式记录在每个 RequestMsg 中
+	Codec       types.Codec `json:"codec,omitempty"`
+	Shards      []int       `json:"shards"`
+	Accounts    map[int]int `json:"accounts,omitempty"`
+	Batches     map[int]int `json:"batches,omitempty"`
+	Partitioner string      `json:"partitioner,omitempty"`
+	Seed        int64       `json:"seed,omitempty"`
+	// NOTE: 相邻批次的时间间隔, 批次的 Timestamp 按照它排列
+	Interval  string `json:"interval,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+func WriteManifest(dir string, m Manifest) error {
+	if m.CreatedAt == 0 {
+		m.CreatedAt = time.Now().UnixNano()
+	}
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, manifestFile), content, 0o644)
+}
+
+func ReadManifest(dir string) (Manifest, error) {
+	m := Manifest{}
+	content, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(content, &m); err != nil {
+		return m, fmt.Errorf("parse manifest in %s: %w", dir, err)
+	}
+	if m.Format == "" {
+		m.Format = JSONL
+	}
+	if _, err := ParseFormat(string(m.Format)); err != nil {
+		return m, err
+	}
+	return m, nil
+}
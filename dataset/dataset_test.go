@@ -0,0 +1,57 @@
+package dataset
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	records := [][]byte{[]byte(`{"a":1}`), []byte(`{"b":"x y"}`), []byte(`{}`)}
+	for _, format := range []Format{JSONL, Binary} {
+		dir := t.TempDir()
+		path := BatchesPath(dir, 3, format)
+		w, err := Create(path, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, record := range records {
+			if err := w.Write(record); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		read, err := ReadAll(path, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(read) != len(records) {
+			t.Fatalf("%s: read %d records, want %d", format, len(read), len(records))
+		}
+		for i := range records {
+			if !bytes.Equal(read[i], records[i]) {
+				t.Fatalf("%s: record %d is %q, want %q", format, i, read[i], records[i])
+			}
+		}
+	}
+
+	// NOTE: 二进制格式的记录可以包含换行, jsonl 不可以
+	w, err := Create(AccountsPath(t.TempDir(), 0, JSONL), JSONL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.Write([]byte("a\nb")); err == nil {
+		t.Fatal("jsonl should reject records with newlines")
+	}
+
+	dir := t.TempDir()
+	if err := WriteManifest(dir, Manifest{Format: Binary, Shards: []int{0, 1}, Batches: map[int]int{0: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ReadManifest(dir)
+	if err != nil || m.Format != Binary || m.Batches[0] != 2 || len(m.Shards) != 2 {
+		t.Fatalf("unexpected manifest %+v (%v)", m, err)
+	}
+}
@@ -1,101 +1,310 @@
 package generator
 
 import (
-	"crypto/rand"
+	"crypto/ecdsa"
 	"errors"
 	"fmt"
-	"generator_boilerplate/constant"
 	"generator_boilerplate/types"
 	"github.com/ethereum/go-ethereum/crypto"
-	"log"
-	"math/big"
+	"io"
 	"reflect"
 )
 
-func GenerateAccounts(number int) ([]types.Account, error) {
+// NOTE: 生成交易时可能被拒绝的原因, ErrInsufficientBalance 定义在 ledger.go
+var (
+	ErrRepetitive        = errors.New("repetitive from and to")
+	ErrCounterExceeded   = errors.New("counter has exceed")
+	ErrNotEnoughAccounts = errors.New("not enough accounts")
+	ErrNoTargetShards    = errors.New("no target shards")
+)
+
+// RejectionReason 返回生成交易失败的原因, 用作指标的标签
+func RejectionReason(err error) string {
+	switch {
+	case errors.Is(err, ErrRepetitive):
+		return "repetitive"
+	case errors.Is(err, ErrCounterExceeded):
+		return "counter_exceeded"
+	case errors.Is(err, ErrInsufficientBalance):
+		return "insufficient_balance"
+	case errors.Is(err, ErrNotEnoughAccounts):
+		return "not_enough_accounts"
+	case errors.Is(err, ErrNoTargetShards):
+		return "no_target_shards"
+	}
+	return "other"
+}
+
+// Options 控制交易生成的约束
+type Options struct {
+	// NOTE: 每个发送方在一个批次内最多发出的交易数
+	MaxTxsInBlock int
+	// NOTE: 发送方与接收方的选择分布, nil 表示均匀分布
+	Selector Selector
+	// NOTE: 影子账本, 按照账本中的余额检查每笔转账, 并跨批次分配 nonce
+	Ledger *Ledger
+	// NOTE: 转账金额的分布, nil 表示每笔转账金额为 1
+	Values ValueDistribution
+	// NOTE: 非 nil 时生成签名后的以太坊交易 (legacy / EIP-1559), 否则使用原生格式签名
+	Ethereum *types.EthereumConfig
+}
+
+func (o Options) selector() Selector {
+	if o.Selector == nil {
+		return Uniform{}
+	}
+	return o.Selector
+}
+
+func GenerateAccounts(src *Source, number int, balance int64) ([]types.Account, error) {
+	return generateAccounts(src, number, balance, nil)
+}
+
+// GenerateShardAccounts 生成 number 个在 p 下属于 shardID 的账户
+func GenerateShardAccounts(src *Source, p Partitioner, shardID, number int, balance int64) ([]types.Account, error) {
+	return generateAccounts(src, number, balance, belongsTo(p, shardID))
+}
+
+// generateAccounts 生成 number 个账户, accept 非 nil 时丢弃不被接受的地址
+func generateAccounts(src *Source, number int, balance int64, accept func(address string) bool) ([]types.Account, error) {
 	accounts := make([]types.Account, number)
-	for i := 0; i < number; i++ {
-		privateKey, err := crypto.GenerateKey()
+	for i, attempts := 0, 0; i < number; attempts++ {
+		if accept != nil && attempts >= maxPartitionAttempts*(i+1) {
+			return nil, ErrPartitionUnreachable
+		}
+		privateKey, err := generateKey(src)
 		if err != nil {
-			log.Fatalf("Failed to generate private key: %v", err)
+			return nil, err
 		}
 
 		privateKeyBytes := crypto.FromECDSA(privateKey)
 		address := crypto.PubkeyToAddress(privateKey.PublicKey).Hex()
+		if accept != nil && !accept(address) {
+			continue
+		}
 
 		accounts[i] = types.Account{
 			PrivateKey: fmt.Sprintf("0x%x", privateKeyBytes),
 			Address:    address,
-			Balance:    constant.Balance,
+			Balance:    balance,
 			Nonce:      0,
 			// ShardList:  make([]int, 0),
 		}
+		i++
 	}
 	return accounts, nil
 }
 
-func GenerateTransaction(addresses []types.Account, counter *map[string]int, repetitive *map[string][]string, noncer *map[string]int64) (*types.Transaction, error) {
-	indexFrom, indexTo := 0, 0
-	for indexFrom == indexTo {
-		indexFromInt64, _ := rand.Int(rand.Reader, big.NewInt(int64(len(addresses))))
-		indexFrom = int(indexFromInt64.Int64())
-		indexToInt64, _ := rand.Int(rand.Reader, big.NewInt(int64(len(addresses))))
-		indexTo = int(indexToInt64.Int64())
+// signer 是 Transaction 与 CrossShardTransaction 共有的签名方法
+type signer interface {
+	Sign(privateKey *ecdsa.PrivateKey) error
+	SignEthereum(cfg types.EthereumConfig, privateKey *ecdsa.PrivateKey) error
+}
+
+// sign 按照 o.Ethereum 对交易做原生签名或者以太坊交易签名
+func (o Options) sign(tx signer, privateKey *ecdsa.PrivateKey) error {
+	if o.Ethereum != nil {
+		return tx.SignEthereum(*o.Ethereum, privateKey)
+	}
+	return tx.Sign(privateKey)
+}
+
+// Fee 返回每笔交易在账本中额外扣除的 gas 费用, 原生格式的交易不支付 gas
+// NOTE: EthereumConfig.Validate 保证最大 gas 费用不会溢出 int64
+func (o Options) Fee() int64 {
+	if o.Ethereum == nil {
+		return 0
+	}
+	return o.Ethereum.MaxFee().Int64()
+}
+
+// nonce 将账本分配的 nonce 转换为交易使用的 nonce
+// NOTE: 账本中的 nonce 从 1 开始; 以太坊账户的第一笔交易使用 0, 即分配前的 nonce,
+// 此时账本中的 nonce 等于 shard 上账户已执行的交易数, 与 eth_getTransactionCount 一致
+func (o Options) nonce(allocated int64) int64 {
+	if o.Ethereum != nil {
+		return allocated - 1
+	}
+	return allocated
+}
+
+// value 根据发送方在账本中扣除 gas 费用后的余额抽取转账金额, 不足 1 时返回 ErrInsufficientBalance
+func (o Options) value(src *Source, acc types.Account) (int64, error) {
+	balance, ok := o.Ledger.Balance(acc.Address)
+	if !ok || balance-o.Fee() < 1 {
+		return 0, ErrInsufficientBalance
+	}
+	return boundedValue(o.Values, src, balance-o.Fee()), nil
+}
+
+// generateKey 从随机源中读取 32 字节作为私钥, 直到得到合法的 secp256k1 私钥
+func generateKey(src *Source) (*ecdsa.PrivateKey, error) {
+	buf := make([]byte, 32)
+	for {
+		if _, err := io.ReadFull(src, buf); err != nil {
+			return nil, err
+		}
+		privateKey, err := crypto.ToECDSA(buf)
+		if err == nil {
+			return privateKey, nil
+		}
+	}
+}
+
+func GenerateTransaction(src *Source, opts Options, addresses []types.Account, counter *map[string]int, repetitive *map[string][]string) (*types.Transaction, error) {
+	if opts.Ledger == nil {
+		return &types.Transaction{}, errors.New("ledger is required")
+	}
+	if len(addresses) < 2 {
+		return &types.Transaction{}, ErrNotEnoughAccounts
+	}
+	selector := opts.selector()
+	indexFrom, err := selector.Pick(src, len(addresses))
+	if err != nil {
+		return &types.Transaction{}, err
+	}
+	// NOTE: 接收方从其余 n-1 个账户中选择, 即使 selector 总是返回同一个下标也不会陷入重选
+	indexTo, err := selector.Pick(src, len(addresses)-1)
+	if err != nil {
+		return &types.Transaction{}, err
+	}
+	if indexTo >= indexFrom {
+		indexTo++
 	}
 	if containsString(addresses[indexTo].Address, (*repetitive)[addresses[indexFrom].Address]) {
-		return &types.Transaction{}, errors.New("repetitive from and to")
+		return &types.Transaction{}, ErrRepetitive
 	}
-	if (*counter)[addresses[indexFrom].Address] >= constant.MaxTxsInBlock {
-		return &types.Transaction{}, errors.New("transaction counter has exceed")
+	if (*counter)[addresses[indexFrom].Address] >= opts.MaxTxsInBlock {
+		return &types.Transaction{}, ErrCounterExceeded
 	}
-	if addresses[indexFrom].Balance < 1 {
-		return &types.Transaction{}, errors.New("no sufficient balance")
+	value, err := opts.value(src, addresses[indexFrom])
+	if err != nil {
+		return &types.Transaction{}, err
 	}
-	newTx := types.NewTransaction(addresses[indexFrom].Address,
-		addresses[indexTo].Address, 1, (*noncer)[addresses[indexFrom].Address])
-	_ = newTx.GenerateTransactionHash()
-	if reflect.DeepEqual(newTx.Hash, []byte("")) {
-		// log.Error("[ERROR] Wrong when generate the transaction: nil hash.")
-		return &types.Transaction{}, errors.New("wrong tx hash")
+	newTx, err := BuildTransaction(opts, addresses[indexFrom], addresses[indexTo], value)
+	if err != nil {
+		return &types.Transaction{}, err
 	}
 	(*repetitive)[addresses[indexFrom].Address] = append((*repetitive)[addresses[indexFrom].Address], addresses[indexTo].Address)
-	(*noncer)[addresses[indexFrom].Address] += 1
 	(*counter)[addresses[indexFrom].Address] += 1
-	return &newTx, nil
+	return newTx, nil
+}
+
+// BatchPolicy 决定请求的批次大小超出账户能力 (BatchCapacity) 时如何处理
+type BatchPolicy string
+
+const (
+	// NOTE: 发送能生成的部分交易
+	BatchPartial BatchPolicy = "partial"
+	// NOTE: 不生成该批次, 记为 job 的失败
+	BatchError BatchPolicy = "error"
+)
+
+func ParseBatchPolicy(policy string) (BatchPolicy, error) {
+	switch BatchPolicy(policy) {
+	case BatchPartial, BatchError:
+		return BatchPolicy(policy), nil
+	}
+	return "", fmt.Errorf("unknown infeasible batch policy %q", policy)
 }
 
-func GenerateCrossShardTransaction(shardID int, addressMap map[int][]types.Account, counter *map[string]int, repetitive *map[string][]string, noncer *map[string]int64) (*types.CrossShardTransaction, error) {
-	fmt.Println("The length of addressMap is: ", len(addressMap))
-	txIndexFromInt64, _ := rand.Int(rand.Reader, big.NewInt(int64(len(addressMap[shardID]))))
-	txIndexFrom := int(txIndexFromInt64.Int64())
-	if (*counter)[addressMap[shardID][txIndexFrom].Address] >= constant.MaxTxsInBlock {
-		return &types.CrossShardTransaction{}, errors.New("counter has exceed")
+// BatchCapacity 返回 accounts 个账户在一个批次内最多能发出的交易数:
+// 每个发送方最多 maxTxs 笔, 只有片内交易时还受限于不能重复的接收方个数
+func BatchCapacity(accounts, maxTxs int, crossShard bool) int {
+	if crossShard {
+		return accounts * maxTxs
 	}
-	if addressMap[shardID][txIndexFrom].Balance < 1 {
-		return &types.CrossShardTransaction{}, errors.New("no sufficient balance")
+	if accounts < 2 {
+		return 0
 	}
-	indexTo := shardID
-	for indexTo == shardID {
-		// 根据 全局 的 constant 计算 目标 shard 是谁
-		indexToInt64, _ := rand.Int(rand.Reader, big.NewInt(int64(len(addressMap))))
-		indexTo = int(indexToInt64.Int64())
+	return accounts * min(maxTxs, accounts-1)
+}
+
+// GenerateCrossShardTransaction 从 shardID 的账户向 targets 中随机一个 shard 的账户生成跨片交易
+func GenerateCrossShardTransaction(src *Source, opts Options, shardID int, targets []int, addressMap map[int][]types.Account, counter *map[string]int, repetitive *map[string][]string) (*types.CrossShardTransaction, error) {
+	if opts.Ledger == nil {
+		return &types.CrossShardTransaction{}, errors.New("ledger is required")
+	}
+	if len(targets) == 0 {
+		return &types.CrossShardTransaction{}, ErrNoTargetShards
+	}
+	selector := opts.selector()
+	txIndexFrom, err := selector.Pick(src, len(addressMap[shardID]))
+	if err != nil {
+		return &types.CrossShardTransaction{}, err
+	}
+	if (*counter)[addressMap[shardID][txIndexFrom].Address] >= opts.MaxTxsInBlock {
+		return &types.CrossShardTransaction{}, ErrCounterExceeded
+	}
+	value, err := opts.value(src, addressMap[shardID][txIndexFrom])
+	if err != nil {
+		return &types.CrossShardTransaction{}, err
+	}
+	// 根据 当前 的拓扑 计算 目标 shard 是谁
+	targetIndex, err := src.Intn(len(targets))
+	if err != nil {
+		return &types.CrossShardTransaction{}, err
+	}
+	indexTo := targets[targetIndex]
+	if indexTo == shardID {
+		return &types.CrossShardTransaction{}, errors.New("target shard is the source shard")
+	}
+	txIndexTo, err := selector.Pick(src, len(addressMap[indexTo]))
+	if err != nil {
+		return &types.CrossShardTransaction{}, err
 	}
-	fmt.Println("indexTo is: ", indexTo)
-	txIndexToInt64, _ := rand.Int(rand.Reader, big.NewInt(int64(len(addressMap[indexTo]))))
-	txIndexTo := int(txIndexToInt64.Int64())
 	// 如果这对组合的交易已经存在的，也不能保留
 	if containsString(addressMap[indexTo][txIndexTo].Address, (*repetitive)[addressMap[shardID][txIndexFrom].Address]) {
-		return &types.CrossShardTransaction{}, errors.New("repetitive from and to")
+		return &types.CrossShardTransaction{}, ErrRepetitive
 	}
-	newTx := types.NewCrossShardTransaction(shardID, addressMap[shardID][txIndexFrom].Address,
-		addressMap[indexTo][txIndexTo].Address, 1, (*noncer)[addressMap[shardID][txIndexFrom].Address])
-	_ = newTx.GenerateTransactionHash()
-	if reflect.DeepEqual(newTx.Hash, []byte("")) {
-		return &types.CrossShardTransaction{}, errors.New("wrong tx hash")
+	newTx, err := BuildCrossShardTransaction(opts, shardID, addressMap[shardID][txIndexFrom], addressMap[indexTo][txIndexTo], value)
+	if err != nil {
+		return &types.CrossShardTransaction{}, err
 	}
 	(*repetitive)[addressMap[shardID][txIndexFrom].Address] = append((*repetitive)[addressMap[shardID][txIndexFrom].Address], addressMap[indexTo][txIndexTo].Address)
-	(*noncer)[addressMap[shardID][txIndexFrom].Address] += 1
 	(*counter)[addressMap[shardID][txIndexFrom].Address] += 1
+	return newTx, nil
+}
+
+// BuildTransaction 在 opts.Ledger 中记录 from 到 to 的片内转账, 并生成签名后的交易
+func BuildTransaction(opts Options, from, to types.Account, value int64) (*types.Transaction, error) {
+	privateKey, err := from.ECDSA()
+	if err != nil {
+		return &types.Transaction{}, err
+	}
+	// NOTE: 账本在扣款的同时分配 nonce, 保证 nonce 跨批次, 跨 job 连续
+	nonce, err := opts.Ledger.Transfer(from.Address, to.Address, value, opts.Fee())
+	if err != nil {
+		return &types.Transaction{}, err
+	}
+	newTx := types.NewTransaction(from.Address, to.Address, value, opts.nonce(nonce))
+	if err := opts.sign(&newTx, privateKey); err != nil {
+		return &types.Transaction{}, err
+	}
+	if reflect.DeepEqual(newTx.Hash, []byte("")) {
+		// log.Error("[ERROR] Wrong when generate the transaction: nil hash.")
+		return &types.Transaction{}, errors.New("wrong tx hash")
+	}
+	return &newTx, nil
+}
+
+// BuildCrossShardTransaction 在 opts.Ledger 中记录 shardID 的 from 到其他 shard 的 to 的跨片转账, 并生成签名后的交易
+func BuildCrossShardTransaction(opts Options, shardID int, from, to types.Account, value int64) (*types.CrossShardTransaction, error) {
+	privateKey, err := from.ECDSA()
+	if err != nil {
+		return &types.CrossShardTransaction{}, err
+	}
+	nonce, err := opts.Ledger.TransferCrossShard(from.Address, to.Address, value, opts.Fee())
+	if err != nil {
+		return &types.CrossShardTransaction{}, err
+	}
+	newTx := types.NewCrossShardTransaction(shardID, from.Address, to.Address, value, opts.nonce(nonce))
+	if err := opts.sign(&newTx, privateKey); err != nil {
+		return &types.CrossShardTransaction{}, err
+	}
+	if reflect.DeepEqual(newTx.Hash, []byte("")) {
+		return &types.CrossShardTransaction{}, errors.New("wrong tx hash")
+	}
 	return &newTx, nil
 }
 
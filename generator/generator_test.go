@@ -1,15 +1,34 @@
 package generator
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"generator_boilerplate/constant"
 	"generator_boilerplate/types"
 	"log"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
 )
 
+// testOptions 返回以 accounts 初始化账本的生成参数
+func testOptions(accounts ...[]types.Account) Options {
+	ledger := NewLedger()
+	for shardID, accs := range accounts {
+		ledger.SetAccounts(shardID, accs)
+	}
+	return Options{MaxTxsInBlock: constant.MaxTxsInBlock, Ledger: ledger}
+}
+
 func TestGenerateAccounts(t *testing.T) {
-	accounts, err := GenerateAccounts(100)
+	accounts, err := GenerateAccounts(NewSource(0), 100, constant.Balance)
 	if err != nil {
 		log.Println(err)
 	}
@@ -33,3 +52,449 @@ func TestDecoder(t *testing.T) {
 		fmt.Println(string(v))
 	}
 }
+
+func TestGenerateTransactionSignature(t *testing.T) {
+	accounts, err := GenerateAccounts(NewSource(0), 10, constant.Balance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := testOptions(accounts)
+	counter := make(map[string]int)
+	repetitive := make(map[string][]string)
+	tx, err := GenerateTransaction(NewSource(0), opts, accounts, &counter, &repetitive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Verify(); err != nil {
+		t.Fatalf("verify signed transaction: %v", err)
+	}
+
+	tx.Value += 1
+	if err := tx.Verify(); err == nil {
+		t.Fatal("tampered transaction should not verify")
+	}
+
+	addressMap := map[int][]types.Account{0: accounts[:5], 1: accounts[5:]}
+	ctx, err := GenerateCrossShardTransaction(NewSource(0), opts, 0, []int{1}, addressMap, &counter, &repetitive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.Verify(); err != nil {
+		t.Fatalf("verify signed cross shard transaction: %v", err)
+	}
+	// NOTE: 重新计算 hash, 使校验越过 hash 检查, 由签名恢复出的签名者与伪造的发送方不一致
+	ctx.From = accounts[6].Address
+	if err := ctx.GenerateTransactionHash(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.Verify(); !errors.Is(err, types.ErrSignerMismatch) {
+		t.Fatalf("transaction with forged sender should fail the signer check, have %v", err)
+	}
+}
+
+func TestSeededGenerationIsDeterministic(t *testing.T) {
+	generate := func() []byte {
+		src := DeriveSource(42, 0)
+		accounts, err := GenerateAccounts(src, 20, constant.Balance)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := testOptions(accounts)
+		counter := make(map[string]int)
+		repetitive := make(map[string][]string)
+		out := make([]byte, 0)
+		for i := 0; i < 10; i++ {
+			tx, err := GenerateTransaction(src, opts, accounts, &counter, &repetitive)
+			if err != nil {
+				continue
+			}
+			encoded, _ := tx.Marshal()
+			out = append(out, encoded...)
+		}
+		return out
+	}
+	if !bytes.Equal(generate(), generate()) {
+		t.Fatal("same seed should produce identical transactions")
+	}
+}
+
+func TestDeriveAccounts(t *testing.T) {
+	accounts, err := DeriveAccounts("test test test test test test test test test test test junk", 0, 2, constant.Balance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NOTE: hardhat / anvil 默认助记词在 m/44'/60'/0'/0/i 下的前两个地址
+	expected := []string{"0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266", "0x70997970C51812dc3A010C7d01b50e0d17dc79C8"}
+	for i, acc := range accounts {
+		if acc.Address != expected[i] {
+			t.Fatalf("account %d: have %s, want %s", i, acc.Address, expected[i])
+		}
+	}
+}
+
+func TestSelectorSkew(t *testing.T) {
+	src := NewSource(7)
+	const n, draws = 100, 20000
+	hotspot, _ := NewSelector(DistributionHotspot, 0, 0.8, 0.2)
+	zipf, _ := NewSelector(DistributionZipf, 0.99, 0, 0)
+	for _, selector := range []Selector{hotspot, zipf} {
+		hot := 0
+		for i := 0; i < draws; i++ {
+			index, err := selector.Pick(src, n)
+			if err != nil || index < 0 || index >= n {
+				t.Fatalf("%s: invalid pick %d: %v", selector, index, err)
+			}
+			if index < n/5 {
+				hot++
+			}
+		}
+		// NOTE: 两种分布下前 20% 的账户都应承担远多于 20% 的选择
+		if ratio := float64(hot) / draws; ratio < 0.6 {
+			t.Fatalf("%s: hot accounts picked %.2f of the time", selector, ratio)
+		}
+	}
+}
+
+func TestDegenerateSelector(t *testing.T) {
+	accounts, err := GenerateAccounts(NewSource(3), 10, constant.Balance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NOTE: 只有一个热点账户且所有选择都落在热点上, 以及 theta 极大的 zipf, 都总是选择下标 0
+	hotspot, _ := NewSelector(DistributionHotspot, 0, 1, 0.01)
+	zipf, _ := NewSelector(DistributionZipf, 1000, 0, 0)
+	for _, selector := range []Selector{hotspot, zipf} {
+		opts := testOptions(accounts)
+		opts.Selector = selector
+		counter := make(map[string]int)
+		repetitive := make(map[string][]string)
+		tx, err := GenerateTransaction(NewSource(1), opts, accounts, &counter, &repetitive)
+		if err != nil {
+			t.Fatalf("%s: %v", selector, err)
+		}
+		if tx.From != accounts[0].Address || tx.To != accounts[1].Address {
+			t.Fatalf("%s: generated %s -> %s", selector, tx.From, tx.To)
+		}
+		if _, err := GenerateTransaction(NewSource(1), opts, accounts, &counter, &repetitive); !errors.Is(err, ErrRepetitive) {
+			t.Fatalf("%s: second pick should repeat the same pair: %v", selector, err)
+		}
+	}
+}
+
+func TestLedgerPreventsOverdraft(t *testing.T) {
+	accounts, err := GenerateAccounts(NewSource(3), 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger := NewLedger()
+	ledger.SetAccounts(0, accounts[:2])
+	ledger.SetAccounts(1, accounts[2:])
+	opts := Options{MaxTxsInBlock: 100, Ledger: ledger}
+	addressMap := map[int][]types.Account{0: accounts[:2], 1: accounts[2:]}
+
+	src := NewSource(3)
+	for i := 0; i < 50; i++ {
+		counter := make(map[string]int)
+		repetitive := make(map[string][]string)
+		_, _ = GenerateTransaction(src, opts, addressMap[0], &counter, &repetitive)
+		_, _ = GenerateCrossShardTransaction(src, opts, 0, []int{1}, addressMap, &counter, &repetitive)
+	}
+
+	total := int64(0)
+	for shardID := 0; shardID < 2; shardID++ {
+		for _, entry := range ledger.Entries(shardID) {
+			if entry.Balance < 0 {
+				t.Fatalf("account %s overdrawn: %d", entry.Address, entry.Balance)
+			}
+			total += entry.Expected
+		}
+	}
+	// NOTE: 转账只在账户之间移动余额, 预期总余额保持不变
+	if total != 8 {
+		t.Fatalf("expected total balance 8, have %d", total)
+	}
+	for _, entry := range ledger.Entries(0) {
+		if entry.Balance != 0 {
+			t.Fatalf("shard 0 account %s should be drained, have %d", entry.Address, entry.Balance)
+		}
+	}
+}
+
+func TestLedgerChargesGas(t *testing.T) {
+	for _, cfg := range []types.EthereumConfig{
+		{Type: types.TxLegacy, ChainID: big.NewInt(1337), GasLimit: 21000, GasPrice: big.NewInt(2)},
+		{Type: types.TxDynamic, ChainID: big.NewInt(1337), GasLimit: 21000, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2)},
+	} {
+		// NOTE: 余额只够支付一笔金额为 1 到 5 的转账与 42000 wei 的最大 gas 费用
+		accounts, err := GenerateAccounts(NewSource(4), 2, 42005)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ledger := NewLedger()
+		ledger.SetAccounts(0, accounts)
+		opts := Options{MaxTxsInBlock: 10, Ledger: ledger, Ethereum: &cfg}
+		tx, err := BuildTransaction(opts, accounts[0], accounts[1], 5)
+		if err != nil {
+			t.Fatalf("%s: %v", cfg.Type, err)
+		}
+		if err := tx.Verify(); err != nil {
+			t.Fatalf("%s: %v", cfg.Type, err)
+		}
+		if balance, _ := ledger.Balance(accounts[0].Address); balance != 0 {
+			t.Fatalf("%s: sender should pay value and max gas cost, have balance %d", cfg.Type, balance)
+		}
+		if balance, _ := ledger.Balance(accounts[1].Address); balance != 42010 {
+			t.Fatalf("%s: receiver should only be credited the value, have balance %d", cfg.Type, balance)
+		}
+		if _, err := BuildTransaction(opts, accounts[1], accounts[0], 42001); !errors.Is(err, ErrInsufficientBalance) {
+			t.Fatalf("%s: transfer exceeding balance minus gas accepted: %v", cfg.Type, err)
+		}
+		if _, err := opts.value(NewSource(4), accounts[0]); !errors.Is(err, ErrInsufficientBalance) {
+			t.Fatalf("%s: drew a value for a sender that cannot pay gas: %v", cfg.Type, err)
+		}
+	}
+}
+
+func TestEthereumNoncesStartAtZero(t *testing.T) {
+	cfg := types.EthereumConfig{Type: types.TxDynamic, ChainID: big.NewInt(1337), GasLimit: 21000, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2)}
+	accounts, err := GenerateAccounts(NewSource(6), 3, constant.Balance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger := NewLedger()
+	ledger.SetAccounts(0, accounts[:2])
+	ledger.SetAccounts(1, accounts[2:])
+	opts := Options{MaxTxsInBlock: 10, Ledger: ledger, Ethereum: &cfg}
+	// NOTE: 新账户的第一笔以太坊交易使用 nonce 0, 片内与跨片交易共用同一个 nonce 序列
+	raws := make([][]byte, 0, 3)
+	for i := 0; i < 2; i++ {
+		tx, err := BuildTransaction(opts, accounts[0], accounts[1], 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raws = append(raws, tx.Raw)
+	}
+	cst, err := BuildCrossShardTransaction(opts, 0, accounts[0], accounts[2], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raws = append(raws, cst.Raw)
+	for i, raw := range raws {
+		decoded := new(gethtypes.Transaction)
+		if err := decoded.UnmarshalBinary(raw); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Nonce() != uint64(i) {
+			t.Fatalf("transaction %d signed with nonce %d", i, decoded.Nonce())
+		}
+	}
+	if err := cst.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	// NOTE: 账本中的 nonce 等于已生成的交易数, 与以太坊节点上的账户 nonce 一致
+	if entry := ledger.Entries(0)[0]; entry.Nonce != 3 {
+		t.Fatalf("ledger nonce should count the generated transactions, have %d", entry.Nonce)
+	}
+}
+
+func TestNoncesCarryAcrossBatches(t *testing.T) {
+	accounts, err := GenerateAccounts(NewSource(5), 2, constant.Balance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := testOptions(accounts)
+	src := NewSource(5)
+	last := make(map[string]int64)
+	for batch := 0; batch < 5; batch++ {
+		// NOTE: 每个批次都重新创建 counter 和 repetitive, 与 server 中的批次一致
+		counter := make(map[string]int)
+		repetitive := make(map[string][]string)
+		tx, err := GenerateTransaction(src, opts, accounts, &counter, &repetitive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tx.Nonce != last[tx.From]+1 {
+			t.Fatalf("batch %d: sender %s nonce %d after %d", batch, tx.From, tx.Nonce, last[tx.From])
+		}
+		last[tx.From] = tx.Nonce
+	}
+
+	ledger := opts.Ledger
+	ledger.ReconcileNonce(accounts[0].Address, 100, GapKeep)
+	if entry := ledger.Entries(0)[0]; entry.Nonce != 100 {
+		t.Fatalf("committed nonce ahead of the ledger should advance it, have %d", entry.Nonce)
+	}
+	ledger.ReconcileNonce(accounts[0].Address, 90, GapKeep)
+	if entry := ledger.Entries(0)[0]; entry.Nonce != 100 {
+		t.Fatalf("keep policy should not rewind, have %d", entry.Nonce)
+	}
+	ledger.ReconcileNonce(accounts[0].Address, 90, GapRewind)
+	if entry := ledger.Entries(0)[0]; entry.Nonce != 90 {
+		t.Fatalf("rewind policy should refill the gap, have %d", entry.Nonce)
+	}
+}
+
+func TestValueDistributions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "values.csv")
+	content := "value,weight\n5,1\n# 区间桶\n100,200,3\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	histogram, err := ValueSpec{Distribution: ValueHistogram, Histogram: path}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := NewSource(11)
+	for i := 0; i < 1000; i++ {
+		if v := histogram.Sample(src); v != 5 && (v < 100 || v > 200) {
+			t.Fatalf("histogram sampled %d outside its buckets", v)
+		}
+	}
+
+	lognormal, err := ValueSpec{Distribution: ValueLogNormal, Mu: 10, Sigma: 2}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		if v := boundedValue(lognormal, src, 50); v < 1 || v > 50 {
+			t.Fatalf("value %d not bounded by balance 50", v)
+		}
+	}
+}
+
+func TestTraceReplayMapping(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "trace.csv")
+	csvTrace := "block,from,to,value\n" +
+		"2,0xaa,0xbb,5\n" +
+		"1,0xAA,0xcc,100000000000000000000\n"
+	if err := os.WriteFile(csvPath, []byte(csvTrace), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	jsonlPath := filepath.Join(dir, "trace.jsonl")
+	jsonlTrace := `{"from":"0xaa","to":"0xcc","value":"100000000000000000000","block":1,"timestamp":10}` + "\n" +
+		`{"from":"0xaa","to":"0xbb","value":5,"block":2,"timestamp":22}` + "\n"
+	if err := os.WriteFile(jsonlPath, []byte(jsonlTrace), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := LoadTrace(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Block != 1 || records[0].Value != math.MaxInt64 || records[1].Value != 5 {
+		t.Fatalf("unexpected csv records %+v", records)
+	}
+	records, err = LoadTrace(jsonlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1].Timestamp != 22 || records[0].Value != math.MaxInt64 {
+		t.Fatalf("unexpected jsonl records %+v", records)
+	}
+
+	src := NewSource(1)
+	addressMap := make(map[int][]types.Account)
+	for shardID := 0; shardID < 2; shardID++ {
+		if addressMap[shardID], err = GenerateAccounts(src, 4, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mapper, err := NewTraceMapper(addressMap, Modulo{Shards: []int{0, 1, 2}}, []int{0, 1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := mapper.Map("0xAA")
+	if again := mapper.Map("0xaa"); again != first {
+		t.Fatalf("address mapped twice: %+v and %+v", first, again)
+	}
+	if first.ShardID == 2 {
+		t.Fatal("mapped to a shard without accounts")
+	}
+}
+
+func TestPartitioners(t *testing.T) {
+	shards := []int{0, 1, 2}
+	if shard := (Modulo{Shards: shards}).Shard("0x00000000000000000000000000000000000000005"); shard != 2 {
+		t.Fatalf("modulo assigned shard %d, want 2", shard)
+	}
+	if shard := (Prefix{Shards: shards}).Shard("0xffff000000000000000000000000000000000000"); shard != 2 {
+		t.Fatalf("prefix assigned shard %d, want 2", shard)
+	}
+
+	// NOTE: 增加一个 shard 时, 一致性哈希只应该移动一部分地址
+	before, after := NewConsistentHash(shards, 100), NewConsistentHash([]int{0, 1, 2, 3}, 100)
+	src := NewSource(5)
+	accounts, err := GenerateAccounts(src, 400, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved := 0
+	for _, acc := range accounts {
+		if b, a := before.Shard(acc.Address), after.Shard(acc.Address); b != a {
+			if a != 3 {
+				t.Fatalf("address moved from shard %d to existing shard %d", b, a)
+			}
+			moved++
+		}
+	}
+	if moved == 0 || moved > len(accounts)/2 {
+		t.Fatalf("consistent hashing moved %d of %d addresses", moved, len(accounts))
+	}
+
+	path := filepath.Join(t.TempDir(), "partition.csv")
+	content := fmt.Sprintf("address,shard\n%s,1\n", accounts[0].Address)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	static, err := PartitionSpec{Strategy: PartitionStatic, File: path}.Build(shards, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shard := static.Shard(accounts[0].Address); shard != 1 {
+		t.Fatalf("static mapping ignored, have shard %d", shard)
+	}
+	if shard := static.Shard(accounts[1].Address); shard != (Modulo{Shards: shards}).Shard(accounts[1].Address) {
+		t.Fatalf("unmapped address should fall back to modulo, have shard %d", shard)
+	}
+
+	generated, err := GenerateShardAccounts(src, before, 1, 10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := PartitionAccounts(before, map[int][]types.Account{0: generated})
+	if len(view[1]) != len(generated) || CrossShard(before, generated[0].Address, generated[1].Address) {
+		t.Fatalf("generated accounts are not all in shard 1: %v", view)
+	}
+}
+
+func TestMigrateAccounts(t *testing.T) {
+	src := NewSource(9)
+	accounts, err := GenerateAccounts(src, 3, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger := NewLedger()
+	ledger.SetAccounts(0, accounts)
+	if _, err := ledger.Transfer(accounts[0].Address, accounts[1].Address, 4, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.Migrate(accounts[0].Address, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(ledger.Entries(0)) != 2 || len(ledger.Entries(1)) != 1 {
+		t.Fatalf("migrated account still listed in its old shard")
+	}
+	if entry := ledger.Entries(1)[0]; entry.Balance != 6 || entry.Nonce != 1 {
+		t.Fatalf("balance and nonce should move with the account, have %+v", entry)
+	}
+
+	base := NewMembership(map[int][]types.Account{0: accounts})
+	overrides := map[string]int{strings.ToLower(accounts[0].Address): 1}
+	partitioner := WithOverrides(base, overrides)
+	overrides[strings.ToLower(accounts[1].Address)] = 1
+	view := PartitionAccounts(partitioner, map[int][]types.Account{0: accounts})
+	if len(view[0]) != 2 || len(view[1]) != 1 || view[1][0].Address != accounts[0].Address {
+		t.Fatalf("unexpected view after migration: %v", view)
+	}
+}
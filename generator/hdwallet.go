@@ -0,0 +1,158 @@
+package generator
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"generator_boilerplate/types"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// HDPathFormat 是每个 shard 的账户派生路径, 第一个参数为 shard, 第二个为账户序号
+const HDPathFormat = "m/44'/60'/%d'/0/%d"
+
+var (
+	ErrInvalidMnemonic = errors.New("invalid mnemonic")
+	errInvalidChildKey = errors.New("invalid child key")
+)
+
+type extendedKey struct {
+	key       []byte
+	chainCode []byte
+}
+
+// ValidateMnemonic 检查助记词的长度, 单词表 (英文) 与 BIP-39 校验和
+func ValidateMnemonic(mnemonic string) error {
+	words := strings.Fields(mnemonic)
+	switch len(words) {
+	case 12, 15, 18, 21, 24:
+	default:
+		return fmt.Errorf("%w: %d words", ErrInvalidMnemonic, len(words))
+	}
+	if !bip39.IsMnemonicValid(strings.Join(words, " ")) {
+		return fmt.Errorf("%w: unknown word or bad checksum", ErrInvalidMnemonic)
+	}
+	return nil
+}
+
+// MnemonicToSeed 按照 BIP-39 将助记词 (及可选密码) 转换为 64 字节的种子
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	if err := ValidateMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+	normalized := strings.Join(strings.Fields(mnemonic), " ")
+	return pbkdf2.Key([]byte(normalized), []byte("mnemonic"+passphrase), 2048, 64, sha512.New), nil
+}
+
+// DeriveAccounts 从助记词沿 m/44'/60'/shard'/0/i 派生 shard 的前 number 个账户
+func DeriveAccounts(mnemonic string, shardID, number int, balance int64) ([]types.Account, error) {
+	return deriveAccounts(mnemonic, shardID, number, balance, nil)
+}
+
+// DeriveShardAccounts 按照派生路径的顺序, 跳过在 p 下不属于 shardID 的账户
+func DeriveShardAccounts(mnemonic string, p Partitioner, shardID, number int, balance int64) ([]types.Account, error) {
+	return deriveAccounts(mnemonic, shardID, number, balance, belongsTo(p, shardID))
+}
+
+func deriveAccounts(mnemonic string, shardID, number int, balance int64, accept func(address string) bool) ([]types.Account, error) {
+	seed, err := MnemonicToSeed(mnemonic, "")
+	if err != nil {
+		return nil, err
+	}
+	master, err := newMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	// NOTE: 先派生到 m/44'/60'/shard'/0, 再逐个派生叶子节点, 避免重复计算公共前缀
+	path, err := accounts.ParseDerivationPath(fmt.Sprintf(HDPathFormat, shardID, 0))
+	if err != nil {
+		return nil, err
+	}
+	parent := master
+	for _, index := range path[:len(path)-1] {
+		if parent, err = parent.child(index); err != nil {
+			return nil, err
+		}
+	}
+
+	accs := make([]types.Account, 0, number)
+	for i := 0; len(accs) < number; i++ {
+		if accept != nil && i >= maxPartitionAttempts*(len(accs)+1) {
+			return nil, ErrPartitionUnreachable
+		}
+		leaf, err := parent.child(uint32(i))
+		if err != nil {
+			return nil, fmt.Errorf("derive %s: %w", fmt.Sprintf(HDPathFormat, shardID, i), err)
+		}
+		privateKey, err := crypto.ToECDSA(leaf.key)
+		if err != nil {
+			return nil, err
+		}
+		address := crypto.PubkeyToAddress(privateKey.PublicKey).Hex()
+		if accept != nil && !accept(address) {
+			continue
+		}
+		accs = append(accs, types.Account{
+			PrivateKey: fmt.Sprintf("0x%x", leaf.key),
+			Address:    address,
+			Balance:    balance,
+			Nonce:      0,
+		})
+	}
+	return accs, nil
+}
+
+func newMasterKey(seed []byte) (*extendedKey, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	if !validKey(sum[:32]) {
+		return nil, errInvalidChildKey
+	}
+	return &extendedKey{key: sum[:32], chainCode: sum[32:]}, nil
+}
+
+// child 按照 BIP-32 派生私钥子节点, index >= 2^31 时为 hardened 派生
+func (k *extendedKey) child(index uint32) (*extendedKey, error) {
+	data := make([]byte, 0, 37)
+	if index >= 0x80000000 {
+		data = append(data, 0x00)
+		data = append(data, k.key...)
+	} else {
+		privateKey, err := crypto.ToECDSA(k.key)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, crypto.CompressPubkey(&privateKey.PublicKey)...)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	n := crypto.S256().Params().N
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(n) >= 0 {
+		return nil, errInvalidChildKey
+	}
+	childKey := il.Add(il, new(big.Int).SetBytes(k.key))
+	childKey.Mod(childKey, n)
+	if childKey.Sign() == 0 {
+		return nil, errInvalidChildKey
+	}
+	return &extendedKey{key: childKey.FillBytes(make([]byte, 32)), chainCode: sum[32:]}, nil
+}
+
+func validKey(key []byte) bool {
+	k := new(big.Int).SetBytes(key)
+	return k.Sign() > 0 && k.Cmp(crypto.S256().Params().N) < 0
+}
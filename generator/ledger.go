@@ -0,0 +1,194 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"generator_boilerplate/types"
+	"sync"
+)
+
+var ErrInsufficientBalance = errors.New("no sufficient balance")
+
+// GapPolicy 决定 shard 上已提交的 nonce 落后于账本 (之前生成的交易被丢弃) 时如何处理
+type GapPolicy string
+
+const (
+	// NOTE: 保持账本中的 nonce, 认为落后的交易仍在途中
+	GapKeep GapPolicy = "keep"
+	// NOTE: 回退到已提交的 nonce, 之后的交易重新使用被丢弃交易的 nonce 填补空洞
+	GapRewind GapPolicy = "rewind"
+)
+
+func ParseGapPolicy(policy string) (GapPolicy, error) {
+	switch GapPolicy(policy) {
+	case GapKeep, GapRewind:
+		return GapPolicy(policy), nil
+	}
+	return "", fmt.Errorf("unknown nonce gap policy %q", policy)
+}
+
+// LedgerEntry 是影子账本中一个账户的状态
+type LedgerEntry struct {
+	ShardID int    `json:"shard_id"`
+	Address string `json:"address"`
+	Balance int64  `json:"balance"`
+	// NOTE: 跨片交易已在源 shard 扣款, 但尚未在本 shard 入账的金额
+	Pending int64 `json:"pending"`
+	// NOTE: 该账户已生成的交易数, 原生格式的下一笔交易使用 Nonce + 1, 以太坊交易从 0 开始, 使用 Nonce
+	Nonce int64 `json:"nonce"`
+	// NOTE: 所有已生成交易都被执行后, shard 上该账户应有的余额
+	// 以太坊交易按照最大 gas 费用扣款, 实际 gas 费用更低时 shard 上的余额会高于该值
+	Expected int64 `json:"expected"`
+}
+
+// Ledger 是生成器维护的影子账本, 记录每笔已生成转账对余额的影响,
+// 保证不会生成透支的交易, 并给出各 shard 预期的最终余额用于校验
+type Ledger struct {
+	mu       sync.Mutex
+	entries  map[string]*LedgerEntry
+	accounts map[int][]string
+}
+
+func NewLedger() *Ledger {
+	return &Ledger{
+		entries:  make(map[string]*LedgerEntry),
+		accounts: make(map[int][]string),
+	}
+}
+
+// SetAccounts 用 shard 新的账户集合替换账本中该 shard 原有的账户
+func (l *Ledger) SetAccounts(shardID int, accounts []types.Account) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, address := range l.accounts[shardID] {
+		delete(l.entries, address)
+	}
+	addresses := make([]string, len(accounts))
+	for i, acc := range accounts {
+		addresses[i] = acc.Address
+		l.entries[acc.Address] = &LedgerEntry{ShardID: shardID, Address: acc.Address, Balance: acc.Balance, Pending: acc.Pending, Nonce: acc.Nonce}
+	}
+	l.accounts[shardID] = addresses
+}
+
+// Migrate 将账户移动到 shardID, 余额, 待入账金额与 nonce 随账户一起迁移
+func (l *Ledger) Migrate(address string, shardID int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry, ok := l.entries[address]
+	if !ok {
+		return errors.New("unknown account")
+	}
+	if entry.ShardID == shardID {
+		return nil
+	}
+	addresses := l.accounts[entry.ShardID]
+	for i, addr := range addresses {
+		if addr == address {
+			l.accounts[entry.ShardID] = append(addresses[:i:i], addresses[i+1:]...)
+			break
+		}
+	}
+	entry.ShardID = shardID
+	l.accounts[shardID] = append(l.accounts[shardID], address)
+	return nil
+}
+
+// Balance 返回账户当前可用的余额, 账本中不存在的账户返回 false
+func (l *Ledger) Balance(address string) (int64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry, ok := l.entries[address]
+	if !ok {
+		return 0, false
+	}
+	return entry.Balance, true
+}
+
+// ShardOf 返回账户在账本中所属的 shard, 即保存该账户的 AddressMap 项
+func (l *Ledger) ShardOf(address string) (int, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry, ok := l.entries[address]
+	if !ok {
+		return 0, false
+	}
+	return entry.ShardID, true
+}
+
+// Transfer 记录一笔片内转账: 发送方扣除 value 与 gas 费用 fee, 接收方立即入账 value, 返回为该交易分配的 nonce
+func (l *Ledger) Transfer(from, to string, value, fee int64) (int64, error) {
+	return l.apply(from, to, value, fee, false)
+}
+
+// TransferCrossShard 记录一笔跨片转账: 发送方扣除 value 与 gas 费用 fee, 接收方记为待入账, 返回为该交易分配的 nonce
+func (l *Ledger) TransferCrossShard(from, to string, value, fee int64) (int64, error) {
+	return l.apply(from, to, value, fee, true)
+}
+
+func (l *Ledger) apply(from, to string, value, fee int64, crossShard bool) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sender, ok := l.entries[from]
+	if !ok {
+		return 0, errors.New("unknown sender")
+	}
+	if value < 0 || fee < 0 || sender.Balance < fee || sender.Balance-fee < value {
+		return 0, ErrInsufficientBalance
+	}
+	sender.Balance -= value + fee
+	sender.Nonce++
+	if receiver, ok := l.entries[to]; ok {
+		if crossShard {
+			receiver.Pending += value
+		} else {
+			receiver.Balance += value
+		}
+	}
+	return sender.Nonce, nil
+}
+
+// ReconcileNonce 以 shard 上已提交的 nonce 校正账本, 返回校正前后的 nonce
+// NOTE: 已提交的 nonce 超前时总是跟进; 落后时由 policy 决定是否回退, 回退不会恢复被丢弃交易扣除的余额
+func (l *Ledger) ReconcileNonce(address string, committed int64, policy GapPolicy) (int64, int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry, ok := l.entries[address]
+	if !ok {
+		return 0, 0
+	}
+	before := entry.Nonce
+	if committed > entry.Nonce || (committed < entry.Nonce && policy == GapRewind) {
+		entry.Nonce = committed
+	}
+	return before, entry.Nonce
+}
+
+// Entries 按照生成顺序返回 shard 所有账户的状态
+func (l *Ledger) Entries(shardID int) []LedgerEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := make([]LedgerEntry, 0, len(l.accounts[shardID]))
+	for _, address := range l.accounts[shardID] {
+		entry := *l.entries[address]
+		entry.Expected = entry.Balance + entry.Pending
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// Snapshot 返回 accounts 的副本, 其中余额, 待入账金额和 nonce 替换为账本中的值, 用于持久化
+func (l *Ledger) Snapshot(accounts []types.Account) []types.Account {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	snapshot := make([]types.Account, len(accounts))
+	for i, acc := range accounts {
+		snapshot[i] = acc
+		if entry, ok := l.entries[acc.Address]; ok {
+			snapshot[i].Balance = entry.Balance
+			snapshot[i].Pending = entry.Pending
+			snapshot[i].Nonce = entry.Nonce
+		}
+	}
+	return snapshot
+}
@@ -0,0 +1,296 @@
+package generator
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"generator_boilerplate/types"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// NOTE: 账户属于生成它的 shard, 即 AddressMap 中的归属
+	PartitionAccount    = "account"
+	PartitionModulo     = "modulo"
+	PartitionPrefix     = "prefix"
+	PartitionConsistent = "consistent"
+	PartitionStatic     = "static"
+)
+
+// Partitioner 决定地址属于哪个 shard, 返回 -1 表示无法判断
+type Partitioner interface {
+	Shard(address string) int
+	String() string
+}
+
+// PartitionSpec 描述划分策略的参数, 由 Build 构造对应的 Partitioner
+type PartitionSpec struct {
+	Strategy string
+	// NOTE: 一致性哈希中每个 shard 的虚拟节点数
+	VirtualNodes int
+	// NOTE: static 策略的映射文件, 文件中没有的地址按照 modulo 划分
+	File string
+}
+
+// Build 在给定的 shard 集合上构造 Partitioner, account 策略使用 addressMap 中的归属
+func (spec PartitionSpec) Build(shards []int, addressMap map[int][]types.Account) (Partitioner, error) {
+	switch spec.Strategy {
+	case "", PartitionAccount:
+		return NewMembership(addressMap), nil
+	case PartitionModulo:
+		return Modulo{Shards: shards}, nil
+	case PartitionPrefix:
+		return Prefix{Shards: shards}, nil
+	case PartitionConsistent:
+		return NewConsistentHash(shards, spec.VirtualNodes), nil
+	case PartitionStatic:
+		return LoadStaticPartition(spec.File, Modulo{Shards: shards})
+	default:
+		return nil, fmt.Errorf("unknown partition strategy %q", spec.Strategy)
+	}
+}
+
+// NOTE: 按照划分策略生成账户时, 平均每个账户最多尝试的次数
+const maxPartitionAttempts = 10000
+
+// ErrPartitionUnreachable 表示划分策略几乎不会把地址分配给请求的 shard
+var ErrPartitionUnreachable = errors.New("partitioner does not assign addresses to the shard")
+
+func belongsTo(p Partitioner, shardID int) func(address string) bool {
+	return func(address string) bool {
+		return p.Shard(address) == shardID
+	}
+}
+
+// CrossShard 判断一笔从 from 到 to 的转账在 p 下是否为跨片交易
+func CrossShard(p Partitioner, from, to string) bool {
+	return p.Shard(from) != p.Shard(to)
+}
+
+// PartitionAccounts 按照 p 将 addressMap 中的账户重新分组, 无法判断的账户保留在原来的 shard
+func PartitionAccounts(p Partitioner, addressMap map[int][]types.Account) map[int][]types.Account {
+	shardIDs := make([]int, 0, len(addressMap))
+	for shardID := range addressMap {
+		shardIDs = append(shardIDs, shardID)
+	}
+	sort.Ints(shardIDs)
+	view := make(map[int][]types.Account)
+	for _, shardID := range shardIDs {
+		for _, acc := range addressMap[shardID] {
+			target := p.Shard(acc.Address)
+			if target < 0 {
+				target = shardID
+			}
+			view[target] = append(view[target], acc)
+		}
+	}
+	return view
+}
+
+// addressValue 将地址的十六进制字符解析为整数, 不是十六进制的地址退化为 hash
+func addressValue(digits string) uint32 {
+	if v, err := strconv.ParseUint(digits, 16, 32); err == nil {
+		return uint32(v)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(digits))
+	return h.Sum32()
+}
+
+func hexDigits(address string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(address)), "0x")
+}
+
+// Modulo 按照地址最后 8 位十六进制数对 shard 数取模
+type Modulo struct {
+	Shards []int
+}
+
+func (m Modulo) Shard(address string) int {
+	if len(m.Shards) == 0 {
+		return -1
+	}
+	digits := hexDigits(address)
+	if len(digits) > 8 {
+		digits = digits[len(digits)-8:]
+	}
+	return m.Shards[int(addressValue(digits)%uint32(len(m.Shards)))]
+}
+
+func (m Modulo) String() string {
+	return PartitionModulo
+}
+
+// Prefix 按照地址前 8 位十六进制数将地址空间均分为连续的区间
+type Prefix struct {
+	Shards []int
+}
+
+func (p Prefix) Shard(address string) int {
+	if len(p.Shards) == 0 {
+		return -1
+	}
+	digits := hexDigits(address)
+	if len(digits) > 8 {
+		digits = digits[:8]
+	}
+	// NOTE: 不足 8 位的前缀在右侧补 0, 保证区间的划分与地址长度无关
+	digits += strings.Repeat("0", 8-len(digits))
+	return p.Shards[int(uint64(addressValue(digits))*uint64(len(p.Shards))>>32)]
+}
+
+func (p Prefix) String() string {
+	return PartitionPrefix
+}
+
+// ConsistentHash 将 shard 的虚拟节点放在哈希环上, 地址属于顺时针方向的第一个节点
+// NOTE: 增减 shard 时只有相邻区间的地址会改变归属
+type ConsistentHash struct {
+	ring   []uint64
+	owners map[uint64]int
+}
+
+func NewConsistentHash(shards []int, virtualNodes int) *ConsistentHash {
+	if virtualNodes <= 0 {
+		virtualNodes = 1
+	}
+	c := &ConsistentHash{owners: make(map[uint64]int)}
+	for _, shardID := range shards {
+		for i := 0; i < virtualNodes; i++ {
+			point := hash64(fmt.Sprintf("shard-%d-%d", shardID, i))
+			if _, ok := c.owners[point]; ok {
+				continue
+			}
+			c.owners[point] = shardID
+			c.ring = append(c.ring, point)
+		}
+	}
+	sort.Slice(c.ring, func(i, j int) bool { return c.ring[i] < c.ring[j] })
+	return c
+}
+
+func (c *ConsistentHash) Shard(address string) int {
+	if len(c.ring) == 0 {
+		return -1
+	}
+	point := hash64(hexDigits(address))
+	i := sort.Search(len(c.ring), func(i int) bool { return c.ring[i] >= point })
+	if i == len(c.ring) {
+		i = 0
+	}
+	return c.owners[c.ring[i]]
+}
+
+func (c *ConsistentHash) String() string {
+	return PartitionConsistent
+}
+
+func hash64(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// Static 按照固定的地址映射表划分, 表中没有的地址交给 Fallback
+type Static struct {
+	Table    map[string]int
+	Fallback Partitioner
+	name     string
+}
+
+// NewMembership 返回按照 addressMap 中的归属划分的 Static
+func NewMembership(addressMap map[int][]types.Account) *Static {
+	table := make(map[string]int)
+	for shardID, accounts := range addressMap {
+		for _, acc := range accounts {
+			table[strings.ToLower(acc.Address)] = shardID
+		}
+	}
+	return &Static{Table: table, name: PartitionAccount}
+}
+
+// WithOverrides 返回优先使用 overrides (小写地址到 shard) 的 Partitioner, 用于记录账户迁移后的位置
+// NOTE: overrides 会被复制, 之后对它的修改不影响返回的 Partitioner
+func WithOverrides(base Partitioner, overrides map[string]int) Partitioner {
+	if len(overrides) == 0 {
+		return base
+	}
+	table := make(map[string]int, len(overrides))
+	for address, shardID := range overrides {
+		table[address] = shardID
+	}
+	return &Static{Table: table, Fallback: base, name: base.String()}
+}
+
+// LoadStaticPartition 读取 JSON ({"address": shard}) 或 CSV (address,shard) 格式的映射文件
+func LoadStaticPartition(path string, fallback Partitioner) (*Static, error) {
+	if path == "" {
+		return nil, errors.New("static partition requires a mapping file")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	table := make(map[string]int)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		if err := json.NewDecoder(file).Decode(&table); err != nil {
+			return nil, fmt.Errorf("load partition %s: %w", path, err)
+		}
+	case ".csv":
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = 2
+		reader.Comment = '#'
+		for line := 1; ; line++ {
+			row, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("load partition %s: %w", path, err)
+			}
+			shardID, err := strconv.Atoi(strings.TrimSpace(row[1]))
+			if err != nil {
+				// NOTE: 允许第一行是表头
+				if line == 1 {
+					continue
+				}
+				return nil, fmt.Errorf("load partition %s: line %d: invalid shard %q", path, line, row[1])
+			}
+			table[strings.TrimSpace(row[0])] = shardID
+		}
+	default:
+		return nil, fmt.Errorf("unsupported partition format %q", filepath.Ext(path))
+	}
+	static := &Static{Table: make(map[string]int, len(table)), Fallback: fallback, name: PartitionStatic}
+	for address, shardID := range table {
+		static.Table[strings.ToLower(address)] = shardID
+	}
+	return static, nil
+}
+
+func (s *Static) Shard(address string) int {
+	if shardID, ok := s.Table[strings.ToLower(address)]; ok {
+		return shardID
+	}
+	if s.Fallback != nil {
+		return s.Fallback.Shard(address)
+	}
+	return -1
+}
+
+func (s *Static) String() string {
+	if s.name == "" {
+		return PartitionStatic
+	}
+	return s.name
+}
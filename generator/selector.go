@@ -0,0 +1,126 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+const (
+	DistributionUniform = "uniform"
+	DistributionZipf    = "zipf"
+	DistributionHotspot = "hotspot"
+)
+
+// Selector 决定从 n 个账户中选择哪一个作为交易的发送方或接收方
+// NOTE: 账户按其在 AddressMap 中的下标排序, 下标越小越 "热"
+type Selector interface {
+	Pick(src *Source, n int) (int, error)
+	String() string
+}
+
+// NewSelector 根据分布名称创建 Selector, theta 仅用于 zipf, hotTx/hotAccounts 仅用于 hotspot
+func NewSelector(distribution string, theta, hotTx, hotAccounts float64) (Selector, error) {
+	switch distribution {
+	case "", DistributionUniform:
+		return Uniform{}, nil
+	case DistributionZipf:
+		if theta < 0 {
+			return nil, errors.New("zipf theta must not be negative")
+		}
+		return NewZipf(theta), nil
+	case DistributionHotspot:
+		if hotTx < 0 || hotTx > 1 || hotAccounts <= 0 || hotAccounts > 1 {
+			return nil, errors.New("hotspot ratios must be within (0, 1]")
+		}
+		return Hotspot{TxRatio: hotTx, AccountRatio: hotAccounts}, nil
+	}
+	return nil, fmt.Errorf("unknown distribution %q", distribution)
+}
+
+// Uniform 均匀地选择账户
+type Uniform struct{}
+
+func (Uniform) Pick(src *Source, n int) (int, error) {
+	return src.Intn(n)
+}
+
+func (Uniform) String() string {
+	return DistributionUniform
+}
+
+// Zipf 以 1/(i+1)^theta 的概率选择第 i 个账户, theta 为 0 时退化为均匀分布
+type Zipf struct {
+	Theta float64
+
+	mu   sync.Mutex
+	cdfs map[int][]float64
+}
+
+func NewZipf(theta float64) *Zipf {
+	return &Zipf{Theta: theta, cdfs: make(map[int][]float64)}
+}
+
+func (z *Zipf) Pick(src *Source, n int) (int, error) {
+	if n <= 0 {
+		return 0, errors.New("invalid random range")
+	}
+	cdf := z.cdf(n)
+	u := src.Float64()
+	index := sort.SearchFloat64s(cdf, u)
+	if index >= n {
+		index = n - 1
+	}
+	return index, nil
+}
+
+// cdf 返回 n 个账户的累积分布, 按 n 缓存避免每笔交易重复计算
+func (z *Zipf) cdf(n int) []float64 {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if cdf, ok := z.cdfs[n]; ok {
+		return cdf
+	}
+	cdf := make([]float64, n)
+	sum := 0.0
+	for i := 0; i < n; i++ {
+		sum += 1 / math.Pow(float64(i+1), z.Theta)
+		cdf[i] = sum
+	}
+	for i := range cdf {
+		cdf[i] /= sum
+	}
+	z.cdfs[n] = cdf
+	return cdf
+}
+
+func (z *Zipf) String() string {
+	return fmt.Sprintf("%s(theta=%g)", DistributionZipf, z.Theta)
+}
+
+// Hotspot 让 TxRatio 比例的选择落在前 AccountRatio 比例的账户上, 其余落在剩下的账户上
+type Hotspot struct {
+	TxRatio      float64
+	AccountRatio float64
+}
+
+func (h Hotspot) Pick(src *Source, n int) (int, error) {
+	if n <= 0 {
+		return 0, errors.New("invalid random range")
+	}
+	hot := int(math.Ceil(float64(n) * h.AccountRatio))
+	if hot >= n {
+		return src.Intn(n)
+	}
+	if src.Float64() < h.TxRatio {
+		return src.Intn(hot)
+	}
+	index, err := src.Intn(n - hot)
+	return hot + index, err
+}
+
+func (h Hotspot) String() string {
+	return fmt.Sprintf("%s(tx=%g,accounts=%g)", DistributionHotspot, h.TxRatio, h.AccountRatio)
+}
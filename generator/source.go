@@ -0,0 +1,99 @@
+package generator
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/big"
+	mrand "math/rand"
+	"sync"
+)
+
+// Source 是生成器的随机源
+// NOTE: seed 为 0 时使用 crypto/rand, 否则使用确定性的 PRNG, 保证相同 seed 下生成的账户与交易完全一致
+type Source struct {
+	mu  sync.Mutex
+	rnd *mrand.Rand
+}
+
+func NewSource(seed int64) *Source {
+	if seed == 0 {
+		return &Source{}
+	}
+	return &Source{rnd: mrand.New(mrand.NewSource(seed))}
+}
+
+// DeriveSource 为每个 shard 派生独立的随机源, 避免多个 shard 并发生成时互相影响随机序列
+func DeriveSource(seed int64, shardID int) *Source {
+	if seed == 0 {
+		return &Source{}
+	}
+	derived := splitmix64(uint64(seed) + uint64(shardID)*0x9e3779b97f4a7c15)
+	if derived == 0 {
+		derived = seed
+	}
+	return NewSource(derived)
+}
+
+func (s *Source) Deterministic() bool {
+	return s.rnd != nil
+}
+
+func (s *Source) Read(p []byte) (int, error) {
+	if s.rnd == nil {
+		return crand.Read(p)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rnd.Read(p)
+}
+
+// Intn 返回 [0, n) 内的随机数
+func (s *Source) Intn(n int) (int, error) {
+	if n <= 0 {
+		return 0, errors.New("invalid random range")
+	}
+	if s.rnd == nil {
+		v, err := crand.Int(crand.Reader, big.NewInt(int64(n)))
+		if err != nil {
+			return 0, err
+		}
+		return int(v.Int64()), nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rnd.Intn(n), nil
+}
+
+// Float64 返回 [0, 1) 内均匀分布的随机数
+func (s *Source) Float64() float64 {
+	if s.rnd == nil {
+		var buf [8]byte
+		if _, err := crand.Read(buf[:]); err != nil {
+			panic(err)
+		}
+		return float64(binary.BigEndian.Uint64(buf[:])>>11) / (1 << 53)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rnd.Float64()
+}
+
+// ExpFloat64 返回均值为 1 的指数分布随机数, 用于生成泊松到达的间隔
+func (s *Source) ExpFloat64() float64 {
+	return -math.Log(1 - s.Float64())
+}
+
+// NormFloat64 返回标准正态分布随机数 (Box-Muller)
+func (s *Source) NormFloat64() float64 {
+	u1, u2 := s.Float64(), s.Float64()
+	return math.Sqrt(-2*math.Log(1-u1)) * math.Cos(2*math.Pi*u2)
+}
+
+func splitmix64(x uint64) int64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return int64(x ^ (x >> 31))
+}
@@ -0,0 +1,212 @@
+package generator
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"generator_boilerplate/types"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TraceRecord 是历史交易 trace 中的一笔转账, Timestamp 为可选的区块时间 (秒)
+type TraceRecord struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Value     int64  `json:"value"`
+	Block     int64  `json:"block"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// LoadTrace 读取 .csv 或 .jsonl 格式的 trace, 并按区块号排序
+// NOTE: CSV 可以带表头 (from,to,value,block[,timestamp]), 否则按这个顺序解析各列;
+// 超出 int64 的金额 (例如以 wei 为单位) 会被截断为 math.MaxInt64
+func LoadTrace(path string) ([]TraceRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []TraceRecord
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		records, err = readCSVTrace(file)
+	case ".jsonl", ".json":
+		records, err = readJSONLTrace(file)
+	default:
+		return nil, fmt.Errorf("unsupported trace format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load trace %s: %w", path, err)
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Block < records[j].Block })
+	return records, nil
+}
+
+func readCSVTrace(r io.Reader) ([]TraceRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	columns := map[string]int{"from": 0, "to": 1, "value": 2, "block": 3, "timestamp": 4}
+	records := make([]TraceRecord, 0)
+	for line := 1; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && isTraceHeader(row) {
+			columns = make(map[string]int)
+			for i, name := range row {
+				columns[strings.ToLower(strings.TrimSpace(name))] = i
+			}
+			continue
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		record := TraceRecord{From: field("from"), To: field("to")}
+		if record.Value, err = parseTraceValue(field("value")); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if record.Block, err = strconv.ParseInt(field("block"), 10, 64); err != nil {
+			return nil, fmt.Errorf("line %d: invalid block: %w", line, err)
+		}
+		if ts := field("timestamp"); ts != "" {
+			if record.Timestamp, err = strconv.ParseInt(ts, 10, 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid timestamp: %w", line, err)
+			}
+		}
+		if record.From == "" || record.To == "" {
+			return nil, fmt.Errorf("line %d: missing from or to", line)
+		}
+		records = append(records, record)
+	}
+}
+
+func readJSONLTrace(r io.Reader) ([]TraceRecord, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	records := make([]TraceRecord, 0)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		raw := struct {
+			From      string      `json:"from"`
+			To        string      `json:"to"`
+			Value     json.Number `json:"value"`
+			Block     int64       `json:"block"`
+			Timestamp int64       `json:"timestamp"`
+		}{}
+		if err := json.Unmarshal([]byte(text), &raw); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		value, err := parseTraceValue(raw.Value.String())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if raw.From == "" || raw.To == "" {
+			return nil, fmt.Errorf("line %d: missing from or to", line)
+		}
+		records = append(records, TraceRecord{From: raw.From, To: raw.To, Value: value, Block: raw.Block, Timestamp: raw.Timestamp})
+	}
+	return records, scanner.Err()
+}
+
+func isTraceHeader(row []string) bool {
+	for _, name := range row {
+		if strings.EqualFold(strings.TrimSpace(name), "from") {
+			return true
+		}
+	}
+	return false
+}
+
+func parseTraceValue(value string) (int64, error) {
+	if value == "" {
+		return 0, errors.New("missing value")
+	}
+	parsed, ok := new(big.Int).SetString(value, 0)
+	if !ok || parsed.Sign() < 0 {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+	if !parsed.IsInt64() {
+		return math.MaxInt64, nil
+	}
+	return parsed.Int64(), nil
+}
+
+// TraceMapper 将 trace 中的原始地址映射到生成的账户上
+// NOTE: 原始地址按照 partitioner 划分到 shard, 划分到没有账户的 shard 时退化为 hash;
+// 同一 shard 内按首次出现的顺序轮流分配账户
+type TraceMapper struct {
+	partitioner Partitioner
+	shards      []int
+	accounts    map[int][]types.Account
+	mapped      map[string]TraceAccount
+	next        map[int]int
+}
+
+// TraceAccount 是原始地址映射到的 shard 和账户
+type TraceAccount struct {
+	ShardID int
+	Account types.Account
+}
+
+func NewTraceMapper(addressMap map[int][]types.Account, partitioner Partitioner, shards []int) (*TraceMapper, error) {
+	usable := make([]int, 0, len(shards))
+	for _, shardID := range shards {
+		if len(addressMap[shardID]) > 0 {
+			usable = append(usable, shardID)
+		}
+	}
+	if len(usable) == 0 {
+		return nil, errors.New("no shards with accounts")
+	}
+	return &TraceMapper{
+		partitioner: partitioner,
+		shards:      usable,
+		accounts:    addressMap,
+		mapped:      make(map[string]TraceAccount),
+		next:        make(map[int]int),
+	}, nil
+}
+
+func (m *TraceMapper) Map(address string) TraceAccount {
+	address = strings.ToLower(address)
+	if mapped, ok := m.mapped[address]; ok {
+		return mapped
+	}
+	shardID := -1
+	if m.partitioner != nil {
+		shardID = m.partitioner.Shard(address)
+	}
+	accounts := m.accounts[shardID]
+	if len(accounts) == 0 {
+		h := fnv.New32a()
+		h.Write([]byte(address))
+		shardID = m.shards[int(h.Sum32()%uint32(len(m.shards)))]
+		accounts = m.accounts[shardID]
+	}
+	mapped := TraceAccount{ShardID: shardID, Account: accounts[m.next[shardID]%len(accounts)]}
+	m.next[shardID]++
+	m.mapped[address] = mapped
+	return mapped
+}
@@ -0,0 +1,221 @@
+package generator
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	ValueConstant  = "constant"
+	ValueUniform   = "uniform"
+	ValueLogNormal = "lognormal"
+	ValueHistogram = "histogram"
+)
+
+// ValueDistribution 决定每笔转账的金额, 实际金额还会被发送方的余额截断
+type ValueDistribution interface {
+	Sample(src *Source) int64
+	String() string
+}
+
+// ValueSpec 描述转账金额的分布及其参数
+type ValueSpec struct {
+	Distribution string
+	// NOTE: constant 使用 Constant, uniform 使用 [Min, Max], lognormal 使用 exp(Mu + Sigma * N(0, 1))
+	Constant  int64
+	Min       int64
+	Max       int64
+	Mu        float64
+	Sigma     float64
+	Histogram string
+}
+
+func (spec ValueSpec) Build() (ValueDistribution, error) {
+	switch spec.Distribution {
+	case "", ValueConstant:
+		if spec.Constant < 1 {
+			return nil, errors.New("constant value must be positive")
+		}
+		return ConstantValue{Value: spec.Constant}, nil
+	case ValueUniform:
+		if spec.Min < 1 || spec.Max < spec.Min {
+			return nil, errors.New("uniform value range must satisfy 1 <= min <= max")
+		}
+		return UniformValue{Min: spec.Min, Max: spec.Max}, nil
+	case ValueLogNormal:
+		if spec.Sigma < 0 {
+			return nil, errors.New("lognormal sigma must not be negative")
+		}
+		return LogNormalValue{Mu: spec.Mu, Sigma: spec.Sigma}, nil
+	case ValueHistogram:
+		return LoadHistogram(spec.Histogram)
+	}
+	return nil, fmt.Errorf("unknown value distribution %q", spec.Distribution)
+}
+
+// boundedValue 从分布中抽取金额, 并限制在 [1, balance] 内
+func boundedValue(values ValueDistribution, src *Source, balance int64) int64 {
+	value := int64(1)
+	if values != nil {
+		value = values.Sample(src)
+	}
+	if value > balance {
+		value = balance
+	}
+	if value < 1 {
+		value = 1
+	}
+	return value
+}
+
+type ConstantValue struct {
+	Value int64
+}
+
+func (c ConstantValue) Sample(*Source) int64 {
+	return c.Value
+}
+
+func (c ConstantValue) String() string {
+	return fmt.Sprintf("%s(%d)", ValueConstant, c.Value)
+}
+
+type UniformValue struct {
+	Min int64
+	Max int64
+}
+
+func (u UniformValue) Sample(src *Source) int64 {
+	return u.Min + int64(src.Float64()*float64(u.Max-u.Min+1))
+}
+
+func (u UniformValue) String() string {
+	return fmt.Sprintf("%s(%d,%d)", ValueUniform, u.Min, u.Max)
+}
+
+type LogNormalValue struct {
+	Mu    float64
+	Sigma float64
+}
+
+func (l LogNormalValue) Sample(src *Source) int64 {
+	value := math.Exp(l.Mu + l.Sigma*src.NormFloat64())
+	if value >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(math.Round(value))
+}
+
+func (l LogNormalValue) String() string {
+	return fmt.Sprintf("%s(mu=%g,sigma=%g)", ValueLogNormal, l.Mu, l.Sigma)
+}
+
+// HistogramBucket 是经验分布中的一个区间 [Min, Max] 及其权重, Min == Max 时表示单个金额
+type HistogramBucket struct {
+	Min    int64
+	Max    int64
+	Weight float64
+}
+
+// HistogramValue 先按权重选择区间, 再在区间内均匀抽取金额
+type HistogramValue struct {
+	Path    string
+	Buckets []HistogramBucket
+	cdf     []float64
+}
+
+// LoadHistogram 读取经验分布文件, 每行为 "value,weight" 或 "min,max,weight", 忽略空行, # 注释和表头
+func LoadHistogram(path string) (*HistogramValue, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	histogram := &HistogramValue{Path: path}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		bucket, err := parseBucket(strings.Split(text, ","))
+		if err != nil {
+			if len(histogram.Buckets) == 0 && line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		histogram.Buckets = append(histogram.Buckets, bucket)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return histogram, histogram.init()
+}
+
+func parseBucket(fields []string) (HistogramBucket, error) {
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	bucket := HistogramBucket{}
+	var err error
+	switch len(fields) {
+	case 2:
+		if bucket.Min, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+			return bucket, err
+		}
+		bucket.Max = bucket.Min
+	case 3:
+		if bucket.Min, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+			return bucket, err
+		}
+		if bucket.Max, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+			return bucket, err
+		}
+	default:
+		return bucket, fmt.Errorf("expected 2 or 3 columns, have %d", len(fields))
+	}
+	if bucket.Weight, err = strconv.ParseFloat(fields[len(fields)-1], 64); err != nil {
+		return bucket, err
+	}
+	if bucket.Min < 1 || bucket.Max < bucket.Min || bucket.Weight < 0 {
+		return bucket, errors.New("invalid bucket")
+	}
+	return bucket, nil
+}
+
+func (h *HistogramValue) init() error {
+	h.cdf = make([]float64, len(h.Buckets))
+	sum := 0.0
+	for i, bucket := range h.Buckets {
+		sum += bucket.Weight
+		h.cdf[i] = sum
+	}
+	if sum <= 0 {
+		return errors.New("histogram has no weight")
+	}
+	for i := range h.cdf {
+		h.cdf[i] /= sum
+	}
+	return nil
+}
+
+func (h *HistogramValue) Sample(src *Source) int64 {
+	index := sort.SearchFloat64s(h.cdf, src.Float64())
+	if index >= len(h.Buckets) {
+		index = len(h.Buckets) - 1
+	}
+	bucket := h.Buckets[index]
+	return bucket.Min + int64(src.Float64()*float64(bucket.Max-bucket.Min+1))
+}
+
+func (h *HistogramValue) String() string {
+	return fmt.Sprintf("%s(%s)", ValueHistogram, h.Path)
+}
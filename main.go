@@ -1,12 +1,30 @@
 package main
 
 import (
-	"generator_boilerplate/constant"
-	"generator_boilerplate/server"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
 )
 
+// 用法: generator [serve|accounts|txs|replay] [flags], 没有指定子命令时启动 HTTP 服务
 func main() {
-	port := constant.Port
-	ser := server.NewServer(port)
-	ser.Start()
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	command, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q, expected serve, accounts, txs or replay\n", name)
+		os.Exit(2)
+	}
+	err := command(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to run %s: %v", name, err)
+	}
 }
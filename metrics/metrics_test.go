@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounter("requests_total", "Requests by code.", "shard", "code")
+	histogram := registry.NewHistogram("batch_size", "Batch sizes.", []float64{10, 1}, "shard")
+	counter.Inc("0", "200")
+	counter.Add(2, "0", "200")
+	counter.Inc("1", `say "hi"`)
+	histogram.Observe(1, "0")
+	histogram.Observe(5, "0")
+	histogram.Observe(50, "0")
+
+	var buf bytes.Buffer
+	if err := registry.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"# HELP requests_total Requests by code.",
+		"# TYPE requests_total counter",
+		`requests_total{shard="0",code="200"} 3`,
+		`requests_total{shard="1",code="say \"hi\""} 1`,
+		"# HELP batch_size Batch sizes.",
+		"# TYPE batch_size histogram",
+		`batch_size_bucket{shard="0",le="1"} 1`,
+		`batch_size_bucket{shard="0",le="10"} 2`,
+		`batch_size_bucket{shard="0",le="+Inf"} 3`,
+		`batch_size_sum{shard="0"} 56`,
+		`batch_size_count{shard="0"} 3`,
+	}, "\n") + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected exposition:\n%s", buf.String())
+	}
+}
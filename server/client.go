@@ -0,0 +1,205 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// NOTE: 单次退避的上限, 避免重试次数较多时等待过久
+	maxRetryBackoff = 10 * time.Second
+	// NOTE: 内存中保留的死信数, 超出后丢弃最早的记录, 完整记录见 state_dir 下的文件
+	maxDeadLetters  = 1000
+	deadLettersFile = "dead_letters.jsonl"
+)
+
+// ShardError 是重试后仍然失败的 shard 请求, StatusCode 为 0 表示没有收到响应
+type ShardError struct {
+	ShardID    int
+	Path       string
+	Attempts   int
+	StatusCode int
+	Err        error
+}
+
+func (e *ShardError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("shard %d%s responded with status code %d after %d attempts", e.ShardID, e.Path, e.StatusCode, e.Attempts)
+	}
+	return fmt.Sprintf("shard %d%s unreachable after %d attempts: %v", e.ShardID, e.Path, e.Attempts, e.Err)
+}
+
+func (e *ShardError) Unwrap() error {
+	return e.Err
+}
+
+// retryable 判断是否值得重试: 网络错误, 5xx 与 429
+func retryable(statusCode int) bool {
+	return statusCode == 0 || statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+// backoff 返回第 attempt 次重试 (从 0 开始) 前的等待时间, 每次翻倍并加入随机抖动
+func backoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	d := base << uint(attempt)
+	if d > maxRetryBackoff || d <= 0 {
+		d = maxRetryBackoff
+	}
+	// NOTE: 等待时间在 [d/2, d) 之间, 避免多个 job 同时重试
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// postToShard 将 body POST 到 shard #0 节点的 path 上, 网络错误, 5xx 与 429 会按照退避时间重试,
+// 每次请求都会记录延迟与状态码 (网络错误记为 "error")
+// NOTE: 最终未得到 200 时返回 *ShardError 并关闭 resp.Body, 否则由调用方负责关闭
+func (s *Server) postToShard(shardID int, path string, body []byte) (*http.Response, error) {
+	leader, err := s.Shards.Leader(shardID)
+	if err != nil {
+		return nil, err
+	}
+	shardErr := &ShardError{ShardID: shardID, Path: path}
+	for attempt := 0; attempt <= s.Config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			s.Metrics.retries.Inc(shardLabel(shardID), path)
+			time.Sleep(backoff(s.Config.RetryBackoff.Duration, attempt-1))
+		}
+		shardErr.Attempts = attempt + 1
+		start := time.Now()
+		resp, err := s.client.Post(leader+path, "application/json", bytes.NewReader(body))
+		s.Metrics.duration.Observe(time.Since(start).Seconds(), shardLabel(shardID), path)
+		if err != nil {
+			s.Metrics.requests.Inc(shardLabel(shardID), path, "error")
+			shardErr.StatusCode, shardErr.Err = 0, err
+			continue
+		}
+		s.Metrics.requests.Inc(shardLabel(shardID), path, strconv.Itoa(resp.StatusCode))
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+		// NOTE: 读完响应体以便复用连接
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		shardErr.StatusCode, shardErr.Err = resp.StatusCode, nil
+		if !retryable(resp.StatusCode) {
+			break
+		}
+	}
+	return nil, shardErr
+}
+
+// DeadLetter 是重试后仍未送达 shard 的请求, Payload 为原始请求体, 可以据此重新发送
+type DeadLetter struct {
+	ShardID    int             `json:"shard_id"`
+	Path       string          `json:"path"`
+	SequenceID int64           `json:"sequenceID,omitempty"`
+	Attempts   int             `json:"attempts"`
+	StatusCode int             `json:"status_code,omitempty"`
+	Error      string          `json:"error"`
+	Time       int64           `json:"time"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// DeadLetters 记录最终失败的请求, 设置了 state_dir 时同时追加到文件中
+type DeadLetters struct {
+	mu      sync.Mutex
+	path    string
+	entries []DeadLetter
+	total   int
+}
+
+func NewDeadLetters(stateDir string) *DeadLetters {
+	d := &DeadLetters{}
+	if stateDir != "" {
+		d.path = filepath.Join(stateDir, deadLettersFile)
+	}
+	return d
+}
+
+func (d *DeadLetters) Add(letter DeadLetter) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.total++
+	d.entries = append(d.entries, letter)
+	if len(d.entries) > maxDeadLetters {
+		d.entries = append([]DeadLetter(nil), d.entries[len(d.entries)-maxDeadLetters:]...)
+	}
+	if d.path == "" {
+		return
+	}
+	if err := d.append(letter); err != nil {
+		log.Printf("[ERROR] Failed to persist dead letter: %v", err)
+	}
+}
+
+func (d *DeadLetters) append(letter DeadLetter) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(d.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// List 返回内存中保留的死信 (最早的在前) 以及累计的死信数
+func (d *DeadLetters) List() ([]DeadLetter, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]DeadLetter(nil), d.entries...), d.total
+}
+
+// deadLetter 在请求因 shard 不可用而失败时记录死信, 其他错误 (例如 shard 未注册) 不记录
+func (s *Server) deadLetter(sequenceID int64, payload []byte, err error) {
+	var shardErr *ShardError
+	if !errors.As(err, &shardErr) {
+		return
+	}
+	log.Printf("[ERROR] Dead-lettered request to shard %d%s: %v", shardErr.ShardID, shardErr.Path, shardErr)
+	s.DeadLetters.Add(DeadLetter{
+		ShardID:    shardErr.ShardID,
+		Path:       shardErr.Path,
+		SequenceID: sequenceID,
+		Attempts:   shardErr.Attempts,
+		StatusCode: shardErr.StatusCode,
+		Error:      shardErr.Error(),
+		Time:       time.Now().UnixNano(),
+		Payload:    payload,
+	})
+}
+
+// handleDeadLetters 返回最近的死信, payload=false 时省略请求体
+func (s *Server) handleDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	letters, total := s.DeadLetters.List()
+	if r.URL.Query().Get("payload") == "false" {
+		for i := range letters {
+			letters[i].Payload = nil
+		}
+	}
+	writeJSON(w, struct {
+		Total   int          `json:"total"`
+		Letters []DeadLetter `json:"dead_letters"`
+	}{Total: total, Letters: letters})
+}
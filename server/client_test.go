@@ -0,0 +1,66 @@
+package server
+
+import (
+	"errors"
+	"generator_boilerplate/config"
+	"generator_boilerplate/store"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPostToShardRetries(t *testing.T) {
+	var calls atomic.Int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer flaky.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer rejecting.Close()
+
+	cfg := config.Default()
+	cfg.RetryBackoff = config.Duration{Duration: time.Millisecond}
+	cfg.ShardsTable = map[string]string{"Shard_0": flaky.URL, "Shard_1": down.URL, "Shard_2": rejecting.URL}
+	s := NewServer(cfg, store.NewMemoryStore())
+
+	resp, err := s.postToShard(0, "/req", []byte(`{}`))
+	if err != nil {
+		t.Fatalf("request should succeed after retries: %v", err)
+	}
+	resp.Body.Close()
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 attempts, have %d", calls.Load())
+	}
+
+	var shardErr *ShardError
+	_, err = s.postToShard(1, "/req", []byte(`{}`))
+	if !errors.As(err, &shardErr) || shardErr.Attempts != cfg.MaxRetries+1 || shardErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("unexpected error %v", err)
+	}
+	s.deadLetter(7, []byte(`{}`), err)
+	if letters, total := s.DeadLetters.List(); total != 1 || letters[0].ShardID != 1 || letters[0].SequenceID != 7 {
+		t.Fatalf("unexpected dead letters %+v", letters)
+	}
+
+	// NOTE: 4xx 不重试
+	_, err = s.postToShard(2, "/req", []byte(`{}`))
+	if !errors.As(err, &shardErr) || shardErr.Attempts != 1 {
+		t.Fatalf("client errors should not be retried: %v", err)
+	}
+
+	for attempt := 0; attempt < 40; attempt++ {
+		if d := backoff(time.Second, attempt); d > maxRetryBackoff || d < 0 {
+			t.Fatalf("backoff %v out of range at attempt %d", d, attempt)
+		}
+	}
+}
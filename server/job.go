@@ -0,0 +1,853 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"generator_boilerplate/generator"
+	"generator_boilerplate/types"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type JobState string
+
+const (
+	JobRunning JobState = "running"
+	JobPaused  JobState = "paused"
+	JobStopped JobState = "stopped"
+)
+
+// JobMode 决定 job 的发送方式
+type JobMode string
+
+const (
+	// NOTE: 每个 interval 发送一批固定大小的交易 (闭环, 突发)
+	ModeInterval JobMode = "interval"
+	// NOTE: 以目标 TPS 为速率, 按指数分布的间隔持续发送小批次 (开环)
+	ModePoisson JobMode = "poisson"
+	// NOTE: 按区块回放以太坊交易 trace, 由 /replay_trace 创建
+	ModeTrace JobMode = "trace"
+	// NOTE: 每个 interval 请求一次账户迁移, 由 /migrate_accounts 创建, Number 为每次迁移的账户数
+	ModeMigration JobMode = "migration"
+	// NOTE: 回放 accounts / txs 子命令生成的数据集, 由 /replay_dataset 或 replay 子命令创建
+	ModeDataset JobMode = "dataset"
+)
+
+var errInvalidParam = errors.New("invalid parameter")
+
+// maxStoppedJobs 是保留 (可以查询状态) 的已停止 job 的数量, 超出时在创建新 job 时丢弃最早停止的 job
+const maxStoppedJobs = 100
+
+// JobConfig 描述 job 的生成参数, 缺省值来自 config.Config, 可以被请求参数覆盖
+type JobConfig struct {
+	ShardID    int
+	IsOverload bool
+	Mode       JobMode
+	Interval   time.Duration
+	// NOTE: poisson 模式下为每次到达发送的交易数
+	Number          int
+	TPS             float64
+	CrossShardRatio int
+	Options         generator.Options
+	// NOTE: 每个批次 (poisson 模式下每个 Interval) 之前从 shard 同步已提交的 nonce
+	NonceSync bool
+	GapPolicy generator.GapPolicy
+	// NOTE: 每个批次最多尝试 Number * AttemptsPerTx 次生成交易
+	AttemptsPerTx int
+	Infeasible    generator.BatchPolicy
+	// NOTE: 批次中交易 (迁移中账户) 的编码方式
+	Codec     types.Codec
+	Trace     *TraceReplay
+	Migration *MigrationConfig
+	Dataset   *DatasetReplay
+}
+
+// Job 是一个为某个 shard 周期性生成并发送交易的任务
+type Job struct {
+	ID              string
+	ShardID         int
+	IsOverload      bool
+	Mode            JobMode
+	CrossShardRatio int
+	Options         generator.Options
+	NonceSync       bool
+	GapPolicy       generator.GapPolicy
+	AttemptsPerTx   int
+	Infeasible      generator.BatchPolicy
+	Codec           types.Codec
+	Trace           *TraceReplay
+	Migration       *MigrationConfig
+	Dataset         *DatasetReplay
+	StartedAt       time.Time
+
+	src    *generator.Source
+	ticker *time.Ticker
+	done   chan struct{}
+
+	mu          sync.Mutex
+	state       JobState
+	interval    time.Duration
+	number      int
+	tps         float64
+	batchesSent int
+	txsSent     int
+	failures    int
+	deadLetters int
+	partial     int
+	// NOTE: 按原因统计的被拒绝的生成尝试
+	rejections map[string]int
+	skipped    int
+	// NOTE: 账本中被本 job 修改过但尚未持久化的 shard, 包括跨片交易记为待入账的目标 shard
+	dirty          map[int]bool
+	migrated       int
+	lastSequenceID int64
+	lastError      string
+	// NOTE: 累计的运行时间 (不含暂停), 用于计算实际达到的 TPS
+	activeFor time.Duration
+	resumedAt time.Time
+	stoppedAt time.Time
+}
+
+// JobStatus 是 Job 的只读快照, 用于 HTTP 接口返回
+type JobStatus struct {
+	ID              string         `json:"job_id"`
+	ShardID         int            `json:"shard_id"`
+	IsOverload      bool           `json:"is_overload"`
+	Mode            JobMode        `json:"mode"`
+	State           JobState       `json:"state"`
+	Interval        string         `json:"interval,omitempty"`
+	Number          int            `json:"number"`
+	TargetTPS       float64        `json:"target_tps,omitempty"`
+	AchievedTPS     float64        `json:"achieved_tps"`
+	CrossShardRatio int            `json:"cross_shard_ratio"`
+	MaxTxsInBlock   int            `json:"max_txs_in_block"`
+	Distribution    string         `json:"distribution"`
+	Values          string         `json:"values"`
+	NonceSync       bool           `json:"nonce_sync"`
+	GapPolicy       string         `json:"gap_policy,omitempty"`
+	BatchesSent     int            `json:"batches_sent"`
+	TxsSent         int            `json:"txs_sent"`
+	Failures        int            `json:"failures"`
+	DeadLetters     int            `json:"dead_letters,omitempty"`
+	AttemptsPerTx   int            `json:"attempts_per_tx,omitempty"`
+	Infeasible      string         `json:"infeasible_batch,omitempty"`
+	Codec           types.Codec    `json:"codec,omitempty"`
+	TxType          types.TxType   `json:"tx_type,omitempty"`
+	ChainID         string         `json:"chain_id,omitempty"`
+	PartialBatches  int            `json:"partial_batches,omitempty"`
+	Rejections      map[string]int `json:"rejections,omitempty"`
+	Skipped         int            `json:"skipped,omitempty"`
+	Trace           string         `json:"trace,omitempty"`
+	Speedup         float64        `json:"speedup,omitempty"`
+	Dataset         string         `json:"dataset,omitempty"`
+	Rate            float64        `json:"rate,omitempty"`
+	TargetShard     *int           `json:"target_shard,omitempty"`
+	Migrated        int            `json:"accounts_migrated,omitempty"`
+	LastSequenceID  int64          `json:"last_sequence_id"`
+	LastError       string         `json:"last_error,omitempty"`
+	StartedAt       int64          `json:"started_at"`
+}
+
+func newJob(id string, cfg JobConfig, src *generator.Source) *Job {
+	now := time.Now()
+	return &Job{
+		ID:              id,
+		ShardID:         cfg.ShardID,
+		IsOverload:      cfg.IsOverload,
+		Mode:            cfg.Mode,
+		CrossShardRatio: cfg.CrossShardRatio,
+		Options:         cfg.Options,
+		NonceSync:       cfg.NonceSync,
+		GapPolicy:       cfg.GapPolicy,
+		AttemptsPerTx:   cfg.AttemptsPerTx,
+		Infeasible:      cfg.Infeasible,
+		Codec:           cfg.Codec,
+		Trace:           cfg.Trace,
+		Migration:       cfg.Migration,
+		Dataset:         cfg.Dataset,
+		StartedAt:       now,
+		src:             src,
+		ticker:          time.NewTicker(cfg.Interval),
+		done:            make(chan struct{}),
+		state:           JobRunning,
+		interval:        cfg.Interval,
+		number:          cfg.Number,
+		tps:             cfg.TPS,
+		resumedAt:       now,
+		rejections:      make(map[string]int),
+		dirty:           make(map[int]bool),
+	}
+}
+
+func (j *Job) Status() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	status := JobStatus{
+		ID:              j.ID,
+		ShardID:         j.ShardID,
+		IsOverload:      j.IsOverload,
+		Mode:            j.Mode,
+		State:           j.state,
+		Number:          j.number,
+		CrossShardRatio: j.CrossShardRatio,
+		MaxTxsInBlock:   j.Options.MaxTxsInBlock,
+		NonceSync:       j.NonceSync,
+		Codec:           j.Codec,
+		BatchesSent:     j.batchesSent,
+		TxsSent:         j.txsSent,
+		Failures:        j.failures,
+		DeadLetters:     j.deadLetters,
+		Skipped:         j.skipped,
+		Migrated:        j.migrated,
+		LastSequenceID:  j.lastSequenceID,
+		LastError:       j.lastError,
+		StartedAt:       j.StartedAt.UnixNano(),
+	}
+	if j.NonceSync {
+		status.GapPolicy = string(j.GapPolicy)
+	}
+	if j.Options.Ethereum != nil {
+		status.TxType, status.ChainID = j.Options.Ethereum.Type, j.Options.Ethereum.ChainID.String()
+	}
+	if len(j.rejections) > 0 {
+		status.Rejections = make(map[string]int, len(j.rejections))
+		for reason, n := range j.rejections {
+			status.Rejections[reason] = n
+		}
+	}
+	switch j.Mode {
+	case ModePoisson:
+		status.TargetTPS = j.tps
+	case ModeTrace:
+		// NOTE: 回放 trace 时账户和金额都来自 trace
+		status.Distribution, status.Values = "trace", "trace"
+		status.Trace, status.Speedup = j.Trace.Path, j.Trace.Speedup
+	case ModeDataset:
+		// NOTE: 数据集中的交易已经生成好, 账户和金额的分布由生成数据集时的参数决定
+		status.Distribution, status.Values = "dataset", "dataset"
+		status.Dataset, status.Rate = j.Dataset.Dir, j.Dataset.Rate
+		if j.Dataset.Rate == 0 {
+			status.Speedup = j.Dataset.Speedup
+		}
+	case ModeMigration:
+		status.Interval = j.interval.String()
+		if j.Migration.ToShard >= 0 {
+			status.TargetShard = &j.Migration.ToShard
+		}
+	default:
+		status.Interval = j.interval.String()
+	}
+	if j.Mode == ModeInterval || j.Mode == ModePoisson {
+		status.AttemptsPerTx = j.AttemptsPerTx
+		status.Infeasible = string(j.Infeasible)
+		status.PartialBatches = j.partial
+	}
+	if j.Mode != ModeTrace && j.Mode != ModeDataset {
+		status.Distribution = j.Options.Selector.String()
+		status.Values = j.Options.Values.String()
+	}
+	if elapsed := j.elapsed().Seconds(); elapsed > 0 {
+		status.AchievedTPS = float64(j.txsSent) / elapsed
+	}
+	return status
+}
+
+// elapsed 返回 job 处于 running 状态的累计时间, 调用方需持有 j.mu
+func (j *Job) elapsed() time.Duration {
+	if j.state == JobRunning {
+		return j.activeFor + time.Since(j.resumedAt)
+	}
+	return j.activeFor
+}
+
+// active 返回 job 处于 running 状态的累计时间
+func (j *Job) active() time.Duration {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.elapsed()
+}
+
+// Done 在 job 停止后被关闭
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+func (j *Job) State() JobState {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state
+}
+
+func (j *Job) stoppedTime() time.Time {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.stoppedAt
+}
+
+func (j *Job) Number() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.number
+}
+
+func (j *Job) Interval() time.Duration {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.interval
+}
+
+func (j *Job) TPS() float64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.tps
+}
+
+func (j *Job) Pause() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state != JobRunning {
+		return fmt.Errorf("job %s is %s", j.ID, j.state)
+	}
+	j.activeFor = j.elapsed()
+	j.state = JobPaused
+	return nil
+}
+
+func (j *Job) Resume() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state != JobPaused {
+		return fmt.Errorf("job %s is %s", j.ID, j.state)
+	}
+	j.state = JobRunning
+	j.resumedAt = time.Now()
+	return nil
+}
+
+// SetRate 修改发送间隔, 每批交易数量与目标 TPS, 非正数表示不修改
+// NOTE: interval 与 migration 模式由 ticker 驱动, 修改间隔时同时重置 ticker; poisson 模式下间隔只决定 nonce 同步的频率
+func (j *Job) SetRate(interval time.Duration, number int, tps float64) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state == JobStopped {
+		return fmt.Errorf("job %s is %s", j.ID, j.state)
+	}
+	if interval > 0 {
+		j.interval = interval
+		if j.Mode == ModeInterval || j.Mode == ModeMigration {
+			j.ticker.Reset(interval)
+		}
+	}
+	if number > 0 {
+		j.number = number
+	}
+	if tps > 0 {
+		j.tps = tps
+	}
+	return nil
+}
+
+func (j *Job) Stop() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state == JobStopped {
+		return fmt.Errorf("job %s is %s", j.ID, j.state)
+	}
+	j.activeFor = j.elapsed()
+	j.state = JobStopped
+	j.stoppedAt = time.Now()
+	close(j.done)
+	return nil
+}
+
+func (j *Job) recordBatch(msg types.RequestMsg, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// NOTE: poisson 模式下批次是异步发送的, 完成顺序不一定与序号一致
+	if msg.SequenceID > j.lastSequenceID {
+		j.lastSequenceID = msg.SequenceID
+	}
+	if err != nil {
+		j.fail(err)
+		return
+	}
+	j.batchesSent++
+	j.txsSent += msg.TransactionNumber
+}
+
+func (j *Job) recordMigration(msg types.MigrationMsg, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if msg.SequenceID > j.lastSequenceID {
+		j.lastSequenceID = msg.SequenceID
+	}
+	if err != nil {
+		j.fail(err)
+		return
+	}
+	j.batchesSent++
+	j.migrated += msg.AddressNumber
+}
+
+// recordFailure 记录一个与具体批次无关的错误
+func (j *Job) recordFailure(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.fail(err)
+}
+
+// fail 记录错误, 重试后仍未送达 shard 的请求同时计入死信数, 调用方需持有 j.mu
+func (j *Job) fail(err error) {
+	j.failures++
+	j.lastError = err.Error()
+	var shardErr *ShardError
+	if errors.As(err, &shardErr) {
+		j.deadLetters++
+	}
+}
+
+// recordRejection 记录一次被拒绝的生成尝试, reason 为 generator.RejectionReason 的结果
+func (j *Job) recordRejection(reason string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.rejections[reason]++
+}
+
+func (j *Job) recordPartial() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.partial++
+}
+
+func (j *Job) recordSkipped(n int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.skipped += n
+}
+
+// markDirty 记录账本中被修改的 shard
+func (j *Job) markDirty(shardID int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.dirty[shardID] = true
+}
+
+// takeDirty 返回并清空尚未持久化的 shard
+func (j *Job) takeDirty() []int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	shardIDs := make([]int, 0, len(j.dirty))
+	for shardID := range j.dirty {
+		shardIDs = append(shardIDs, shardID)
+	}
+	j.dirty = make(map[int]bool)
+	return shardIDs
+}
+
+// wait 等待 d 的时间, 暂停期间不计时; job 被停止时返回 false
+func (j *Job) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	for {
+		select {
+		case <-j.done:
+			return false
+		case <-timer.C:
+			if j.State() == JobRunning {
+				return true
+			}
+			timer.Reset(100 * time.Millisecond)
+		}
+	}
+}
+
+// runJob 在每个 tick 生成并发送一批交易, 直到 job 被停止
+func (s *Server) runJob(job *Job) {
+	defer job.ticker.Stop()
+	if job.Mode == ModePoisson {
+		job.ticker.Stop()
+		s.runPoissonJob(job)
+		return
+	}
+	if job.Mode == ModeTrace {
+		job.ticker.Stop()
+		s.runTraceJob(job)
+		return
+	}
+	if job.Mode == ModeDataset {
+		job.ticker.Stop()
+		s.runDatasetJob(job)
+		return
+	}
+	for {
+		select {
+		case <-job.done:
+			log.Printf("Job %s for shard %d stopped.", job.ID, job.ShardID)
+			return
+		case <-job.ticker.C:
+			if job.State() != JobRunning {
+				continue
+			}
+			if job.Mode == ModeMigration {
+				msg, err := s.migrateAccounts(job)
+				if err != nil {
+					log.Printf("[ERROR] Job %s: %v", job.ID, err)
+				}
+				job.recordMigration(msg, err)
+				continue
+			}
+			if err := s.checkSourceShard(job); err != nil {
+				continue
+			}
+			s.syncJobNonces(job)
+			msg, err := s.generateBatch(job)
+			if err != nil {
+				log.Printf("[ERROR] Job %s: %v", job.ID, err)
+				job.recordFailure(err)
+				continue
+			}
+			s.saveJobShards(job)
+			err = s.submitBatch(job.ShardID, msg)
+			if err != nil {
+				log.Printf("[ERROR] Job %s: %v", job.ID, err)
+			}
+			job.recordBatch(msg, err)
+		}
+	}
+}
+
+// checkSourceShard 检查 job 的源 shard 仍然注册, 被注销时记为 job 的失败
+// NOTE: 必须在生成批次之前检查, 否则交易已经在账本中扣款并分配 nonce, 却无法送达; shard 重新注册后 job 继续生成
+func (s *Server) checkSourceShard(job *Job) error {
+	if _, err := s.Shards.Leader(job.ShardID); err != nil {
+		log.Printf("[ERROR] Job %s: %v", job.ID, err)
+		job.recordFailure(err)
+		return err
+	}
+	return nil
+}
+
+// syncJobNonces 在 job 开启 nonce 同步时校正账本, 同步失败不影响本批次的生成
+func (s *Server) syncJobNonces(job *Job) {
+	if !job.NonceSync {
+		return
+	}
+	report, err := s.syncNonces(job.ShardID, job.GapPolicy)
+	if err != nil {
+		log.Printf("[ERROR] Job %s: %v", job.ID, err)
+		return
+	}
+	if report.Advanced > 0 || report.Rewound > 0 {
+		log.Printf("Job %s synced nonces of shard %d: %d advanced, %d rewound", job.ID, job.ShardID, report.Advanced, report.Rewound)
+	}
+}
+
+// kind 返回 mode 所属的互斥类别, interval 与 poisson 都是生成交易的 job
+func (m JobMode) kind() JobMode {
+	if m == ModePoisson {
+		return ModeInterval
+	}
+	return m
+}
+
+// startJob 为 shard 创建并启动一个 job, 除非 allowMultiple, 否则每个 shard 同一类别 (交易生成, 迁移, trace 回放, 数据集回放)
+// 同时只能有一个活跃的 job
+func (s *Server) startJob(cfg JobConfig, allowMultiple bool, src *generator.Source) (*Job, error) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	if !allowMultiple {
+		for _, job := range s.jobs {
+			sameKind := job.Mode.kind() == cfg.Mode.kind()
+			if job.ShardID == cfg.ShardID && sameKind && job.State() != JobStopped {
+				return nil, fmt.Errorf("shard %d already has an active job %s", cfg.ShardID, job.ID)
+			}
+		}
+	}
+	s.pruneJobsLocked()
+	s.nextJobID++
+	job := newJob("job-"+strconv.Itoa(s.nextJobID), cfg, src)
+	s.jobs[job.ID] = job
+	go s.runJob(job)
+	return job, nil
+}
+
+// pruneJobsLocked 丢弃超出 maxStoppedJobs 的最早停止的 job, 调用方需持有 s.jobsMu
+func (s *Server) pruneJobsLocked() {
+	stopped := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		if job.State() == JobStopped {
+			stopped = append(stopped, job)
+		}
+	}
+	if len(stopped) <= maxStoppedJobs {
+		return
+	}
+	sort.Slice(stopped, func(i, j int) bool { return stopped[i].stoppedTime().Before(stopped[j].stoppedTime()) })
+	for _, job := range stopped[:len(stopped)-maxStoppedJobs] {
+		delete(s.jobs, job.ID)
+	}
+}
+
+func (s *Server) job(r *http.Request) (*Job, bool) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	job, ok := s.jobs[r.URL.Query().Get("id")]
+	return job, ok
+}
+
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.jobsMu.Lock()
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		statuses = append(statuses, job.Status())
+	}
+	s.jobsMu.Unlock()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].StartedAt < statuses[j].StartedAt })
+	writeJSON(w, statuses)
+}
+
+func (s *Server) handleJobStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	job, ok := s.job(r)
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, job.Status())
+}
+
+// handleJobAction 返回一个对 job 执行 action 的 handler, 用于 pause/resume/rate/stop
+func (s *Server) handleJobAction(action func(job *Job, r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		job, ok := s.job(r)
+		if !ok {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		if err := action(job, r); err != nil {
+			status := http.StatusConflict
+			if errors.Is(err, errInvalidParam) {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		writeJSON(w, job.Status())
+	}
+}
+
+// jobConfig 以 Server 的配置为缺省值, 应用请求中的 number/interval/cross_shard_ratio/overload_ratio/max_txs/attempts_per_tx/infeasible 参数
+func (s *Server) jobConfig(shardID int, params url.Values) (JobConfig, error) {
+	cfg := JobConfig{
+		ShardID:         shardID,
+		Mode:            ModeInterval,
+		Interval:        s.Config.GenerationInterval.Duration,
+		Number:          s.Config.TransactionsGeneration,
+		CrossShardRatio: s.Config.CrossShardTransactionRatio,
+		Options:         generator.Options{MaxTxsInBlock: s.Config.MaxTxsInBlock, Ledger: s.Ledger},
+		NonceSync:       s.Config.NonceSync,
+		AttemptsPerTx:   s.Config.AttemptsPerTx,
+	}
+	overloadRatio := s.Config.OverloadTransactionsRatio
+	var err error
+	if param := params.Get("is_overload"); param != "" {
+		if cfg.IsOverload, err = strconv.ParseBool(param); err != nil {
+			return cfg, fmt.Errorf("%w: is_overload %q", errInvalidParam, param)
+		}
+	}
+	if param := params.Get("number"); param != "" {
+		if cfg.Number, err = strconv.Atoi(param); err != nil || cfg.Number <= 0 {
+			return cfg, fmt.Errorf("%w: number %q", errInvalidParam, param)
+		}
+	}
+	if param := params.Get("interval"); param != "" {
+		if cfg.Interval, err = time.ParseDuration(param); err != nil || cfg.Interval <= 0 {
+			return cfg, fmt.Errorf("%w: interval %q", errInvalidParam, param)
+		}
+	}
+	if param := params.Get("cross_shard_ratio"); param != "" {
+		if cfg.CrossShardRatio, err = strconv.Atoi(param); err != nil || cfg.CrossShardRatio < 0 || cfg.CrossShardRatio > 100 {
+			return cfg, fmt.Errorf("%w: cross_shard_ratio %q", errInvalidParam, param)
+		}
+	}
+	if param := params.Get("overload_ratio"); param != "" {
+		if overloadRatio, err = strconv.ParseFloat(param, 64); err != nil || overloadRatio < 0 {
+			return cfg, fmt.Errorf("%w: overload_ratio %q", errInvalidParam, param)
+		}
+	}
+	if param := params.Get("nonce_sync"); param != "" {
+		if cfg.NonceSync, err = strconv.ParseBool(param); err != nil {
+			return cfg, fmt.Errorf("%w: nonce_sync %q", errInvalidParam, param)
+		}
+	}
+	policy := s.Config.NonceGapPolicy
+	if param := params.Get("gap_policy"); param != "" {
+		policy = param
+	}
+	if cfg.GapPolicy, err = generator.ParseGapPolicy(policy); err != nil {
+		return cfg, fmt.Errorf("%w: %v", errInvalidParam, err)
+	}
+	if param := params.Get("max_txs"); param != "" {
+		if cfg.Options.MaxTxsInBlock, err = strconv.Atoi(param); err != nil || cfg.Options.MaxTxsInBlock <= 0 {
+			return cfg, fmt.Errorf("%w: max_txs %q", errInvalidParam, param)
+		}
+	}
+	if param := params.Get("attempts_per_tx"); param != "" {
+		if cfg.AttemptsPerTx, err = strconv.Atoi(param); err != nil || cfg.AttemptsPerTx <= 0 {
+			return cfg, fmt.Errorf("%w: attempts_per_tx %q", errInvalidParam, param)
+		}
+	}
+	infeasible := s.Config.InfeasibleBatch
+	if param := params.Get("infeasible"); param != "" {
+		infeasible = param
+	}
+	if cfg.Infeasible, err = generator.ParseBatchPolicy(infeasible); err != nil {
+		return cfg, fmt.Errorf("%w: %v", errInvalidParam, err)
+	}
+	if cfg.Codec, err = s.codec(params); err != nil {
+		return cfg, fmt.Errorf("%w: %v", errInvalidParam, err)
+	}
+	if cfg.Options.Ethereum, err = s.ethereumConfig(params, cfg.Codec); err != nil {
+		return cfg, fmt.Errorf("%w: %v", errInvalidParam, err)
+	}
+	distribution := s.Config.AccountDistribution
+	theta, hotTx, hotAccounts := s.Config.ZipfTheta, s.Config.HotspotTxRatio, s.Config.HotspotAccountRatio
+	if param := params.Get("dist"); param != "" {
+		distribution = param
+	}
+	for name, value := range map[string]*float64{"theta": &theta, "hot_tx": &hotTx, "hot_acc": &hotAccounts} {
+		if param := params.Get(name); param != "" {
+			if *value, err = strconv.ParseFloat(param, 64); err != nil {
+				return cfg, fmt.Errorf("%w: %s %q", errInvalidParam, name, param)
+			}
+		}
+	}
+	if cfg.Options.Selector, err = generator.NewSelector(distribution, theta, hotTx, hotAccounts); err != nil {
+		return cfg, fmt.Errorf("%w: %v", errInvalidParam, err)
+	}
+	if cfg.Options.Values, err = s.valueDistribution(params); err != nil {
+		return cfg, fmt.Errorf("%w: %v", errInvalidParam, err)
+	}
+	if param := params.Get("mode"); param != "" {
+		switch cfg.Mode = JobMode(param); cfg.Mode {
+		case ModeInterval, ModePoisson:
+		default:
+			return cfg, fmt.Errorf("%w: mode %q", errInvalidParam, param)
+		}
+	}
+	if cfg.IsOverload {
+		cfg.Number = int(math.Round(float64(cfg.Number) * (1 + overloadRatio)))
+	}
+	if cfg.Mode == ModePoisson {
+		param := params.Get("tps")
+		if cfg.TPS, err = strconv.ParseFloat(param, 64); err != nil || cfg.TPS <= 0 {
+			return cfg, fmt.Errorf("%w: tps %q", errInvalidParam, param)
+		}
+		// NOTE: 开环模式默认每次到达只发送一笔交易, 可以用 batch_size 调整
+		cfg.Number = 1
+		if param := params.Get("batch_size"); param != "" {
+			if cfg.Number, err = strconv.Atoi(param); err != nil || cfg.Number <= 0 {
+				return cfg, fmt.Errorf("%w: batch_size %q", errInvalidParam, param)
+			}
+		}
+		if cfg.IsOverload {
+			cfg.TPS *= 1 + overloadRatio
+		}
+	}
+	return cfg, nil
+}
+
+// valueDistribution 以配置为缺省值, 应用请求中的 value_* 参数构造转账金额分布
+func (s *Server) valueDistribution(params url.Values) (generator.ValueDistribution, error) {
+	spec := generator.ValueSpec{
+		Distribution: s.Config.ValueDistribution,
+		Constant:     s.Config.Value,
+		Min:          s.Config.ValueMin,
+		Max:          s.Config.ValueMax,
+		Mu:           s.Config.ValueMu,
+		Sigma:        s.Config.ValueSigma,
+		Histogram:    s.Config.ValueHistogram,
+	}
+	if param := params.Get("value_dist"); param != "" {
+		spec.Distribution = param
+	}
+	if param := params.Get("value_histogram"); param != "" {
+		path, err := s.dataPath(param)
+		if err != nil {
+			return nil, err
+		}
+		spec.Histogram = path
+	}
+	for name, value := range map[string]*int64{"value": &spec.Constant, "value_min": &spec.Min, "value_max": &spec.Max} {
+		if param := params.Get(name); param != "" {
+			var err error
+			if *value, err = strconv.ParseInt(param, 10, 64); err != nil {
+				return nil, fmt.Errorf("%s %q", name, param)
+			}
+		}
+	}
+	for name, value := range map[string]*float64{"value_mu": &spec.Mu, "value_sigma": &spec.Sigma} {
+		if param := params.Get(name); param != "" {
+			var err error
+			if *value, err = strconv.ParseFloat(param, 64); err != nil {
+				return nil, fmt.Errorf("%s %q", name, param)
+			}
+		}
+	}
+	return spec.Build()
+}
+
+func pauseJob(job *Job, _ *http.Request) error {
+	return job.Pause()
+}
+
+func resumeJob(job *Job, _ *http.Request) error {
+	return job.Resume()
+}
+
+func stopJob(job *Job, _ *http.Request) error {
+	return job.Stop()
+}
+
+func setJobRate(job *Job, r *http.Request) error {
+	params := r.URL.Query()
+	var interval time.Duration
+	if param := params.Get("interval"); param != "" {
+		var err error
+		if interval, err = time.ParseDuration(param); err != nil || interval <= 0 {
+			return fmt.Errorf("%w: interval %q", errInvalidParam, param)
+		}
+	}
+	number := 0
+	if param := params.Get("number"); param != "" {
+		var err error
+		if number, err = strconv.Atoi(param); err != nil || number <= 0 {
+			return fmt.Errorf("%w: number %q", errInvalidParam, param)
+		}
+	}
+	tps := 0.0
+	if param := params.Get("tps"); param != "" {
+		var err error
+		if tps, err = strconv.ParseFloat(param, 64); err != nil || tps <= 0 {
+			return fmt.Errorf("%w: tps %q", errInvalidParam, param)
+		}
+	}
+	return job.SetRate(interval, number, tps)
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestJobLifecycle(t *testing.T) {
+	_, base, _ := newTestServer(t, 2)
+	if code := call(t, http.MethodPost, base+"/generate_account?shard_id=0&acc_number=10&seed=1", nil); code != http.StatusOK {
+		t.Fatalf("generate accounts: status %d", code)
+	}
+	// NOTE: 间隔足够长, 测试期间 job 不会发送批次
+	var status JobStatus
+	if code := call(t, http.MethodPost, base+"/generate_transaction?shard_id=0&interval=1h&number=5", &status); code != http.StatusOK {
+		t.Fatalf("start job: status %d", code)
+	}
+	id := status.ID
+	if code := call(t, http.MethodPost, base+"/generate_transaction?shard_id=0&interval=1h&number=5", nil); code != http.StatusConflict {
+		t.Fatalf("second active job on shard 0: status %d", code)
+	}
+
+	var statuses []JobStatus
+	if code := call(t, http.MethodGet, base+"/jobs", &statuses); code != http.StatusOK || len(statuses) != 1 || statuses[0].ID != id {
+		t.Fatalf("list jobs: status %d, %+v", code, statuses)
+	}
+	if code := call(t, http.MethodGet, base+"/jobs/status?id=job-404", nil); code != http.StatusNotFound {
+		t.Fatalf("status of unknown job: status %d", code)
+	}
+
+	steps := []struct {
+		path  string
+		code  int
+		state JobState
+	}{
+		{"/jobs/status", http.StatusOK, JobRunning},
+		{"/jobs/pause", http.StatusOK, JobPaused},
+		{"/jobs/pause", http.StatusConflict, ""},
+		{"/jobs/resume", http.StatusOK, JobRunning},
+		{"/jobs/resume", http.StatusConflict, ""},
+	}
+	for _, step := range steps {
+		status = JobStatus{}
+		method := http.MethodPost
+		if step.path == "/jobs/status" {
+			method = http.MethodGet
+		}
+		if code := call(t, method, base+step.path+"?id="+id, &status); code != step.code {
+			t.Fatalf("%s: status %d, want %d", step.path, code, step.code)
+		}
+		if step.state != "" && status.State != step.state {
+			t.Fatalf("%s: job is %s, want %s", step.path, status.State, step.state)
+		}
+	}
+
+	if code := call(t, http.MethodPost, base+"/jobs/rate?id="+id+"&interval=2h&number=3", &status); code != http.StatusOK {
+		t.Fatalf("rate: status %d", code)
+	}
+	if status.Interval != (2*time.Hour).String() || status.Number != 3 {
+		t.Fatalf("rate not applied: %+v", status)
+	}
+	if code := call(t, http.MethodPost, base+"/jobs/rate?id="+id+"&interval=-1s", nil); code != http.StatusBadRequest {
+		t.Fatalf("invalid rate: status %d", code)
+	}
+
+	if code := call(t, http.MethodPost, base+"/jobs/stop?id="+id, &status); code != http.StatusOK || status.State != JobStopped {
+		t.Fatalf("stop: status %d, job %s", code, status.State)
+	}
+	if code := call(t, http.MethodPost, base+"/jobs/stop?id="+id, nil); code != http.StatusConflict {
+		t.Fatalf("stop a stopped job: status %d", code)
+	}
+	if code := call(t, http.MethodPost, base+"/jobs/rate?id="+id+"&number=1", nil); code != http.StatusConflict {
+		t.Fatalf("rate of a stopped job: status %d", code)
+	}
+	// NOTE: 停止后 shard 可以启动新的 job, 已停止的 job 仍然可以查询
+	if code := call(t, http.MethodPost, base+"/generate_transaction?shard_id=0&interval=1h&number=5", &status); code != http.StatusOK {
+		t.Fatalf("start job after stop: status %d", code)
+	}
+	if code := call(t, http.MethodGet, base+"/jobs", &statuses); code != http.StatusOK || len(statuses) != 2 {
+		t.Fatalf("list jobs after restart: status %d, %d jobs", code, len(statuses))
+	}
+}
+
+func TestMigrationJobRate(t *testing.T) {
+	job := newJob("job-1", JobConfig{Mode: ModeMigration, Interval: time.Hour, Number: 1, Migration: &MigrationConfig{ToShard: -1}}, nil)
+	defer job.ticker.Stop()
+	if err := job.SetRate(time.Millisecond, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if job.Interval() != time.Millisecond {
+		t.Fatalf("migration interval not changed: %s", job.Interval())
+	}
+	// NOTE: ticker 按照新的间隔触发
+	select {
+	case <-job.ticker.C:
+	case <-time.After(time.Second):
+		t.Fatal("migration ticker was not reset")
+	}
+}
+
+func TestPruneStoppedJobs(t *testing.T) {
+	s, _, _ := newTestServer(t, 1)
+	running := newJob("job-running", JobConfig{Mode: ModeInterval, Interval: time.Hour}, nil)
+	s.jobs[running.ID] = running
+	stoppedAt := time.Now()
+	for i := 0; i < maxStoppedJobs+5; i++ {
+		job := newJob(fmt.Sprintf("job-stopped-%d", i), JobConfig{Mode: ModeInterval, Interval: time.Hour}, nil)
+		job.ticker.Stop()
+		_ = job.Stop()
+		job.stoppedAt = stoppedAt.Add(time.Duration(i) * time.Second)
+		s.jobs[job.ID] = job
+	}
+	s.jobsMu.Lock()
+	s.pruneJobsLocked()
+	s.jobsMu.Unlock()
+	if len(s.jobs) != maxStoppedJobs+1 {
+		t.Fatalf("have %d jobs after pruning, want %d", len(s.jobs), maxStoppedJobs+1)
+	}
+	if _, ok := s.jobs[running.ID]; !ok {
+		t.Fatal("running job pruned")
+	}
+	// NOTE: 最早停止的 job 被丢弃
+	if _, ok := s.jobs["job-stopped-0"]; ok {
+		t.Fatal("oldest stopped job kept")
+	}
+}
@@ -0,0 +1,333 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"generator_boilerplate/types"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	TxIntra      = "intra"
+	TxCrossShard = "cross_shard"
+)
+
+const (
+	// NOTE: 每组最多保留的延迟样本数, 超出后覆盖最早的样本
+	maxLatencySamples = 100000
+	// NOTE: 超过该时间仍未收到回执的交易不再等待, 计为过期
+	receiptTimeout = 10 * time.Minute
+)
+
+type latencyKey struct {
+	ShardID int
+	Type    string
+}
+
+type batchKey struct {
+	ShardID    int
+	SequenceID int64
+}
+
+type submission struct {
+	key latencyKey
+	// NOTE: 批次发送前为零值
+	at time.Time
+}
+
+// pendingBatch 是已生成但尚未得到发送结果的批次
+type pendingBatch struct {
+	hashes []string
+	keys   []latencyKey
+	sentAt time.Time
+}
+
+type latencyGroup struct {
+	submitted int
+	confirmed int
+	failed    int
+	expired   int
+	samples   []time.Duration
+	next      int
+	// NOTE: 用于计算确认吞吐量的时间窗口
+	firstSubmitted time.Time
+	lastCommitted  time.Time
+}
+
+func (g *latencyGroup) add(latency time.Duration) {
+	if len(g.samples) < maxLatencySamples {
+		g.samples = append(g.samples, latency)
+		return
+	}
+	g.samples[g.next] = latency
+	g.next = (g.next + 1) % maxLatencySamples
+}
+
+// ReceiptTracker 记录每笔交易的发送时间, 并与 shard 回调的提交回执匹配, 统计端到端延迟
+type ReceiptTracker struct {
+	mu        sync.Mutex
+	batches   map[batchKey]*pendingBatch
+	pending   map[string]submission
+	groups    map[latencyKey]*latencyGroup
+	unmatched int
+	lastPrune time.Time
+}
+
+func NewReceiptTracker() *ReceiptTracker {
+	return &ReceiptTracker{
+		batches:   make(map[batchKey]*pendingBatch),
+		pending:   make(map[string]submission),
+		groups:    make(map[latencyKey]*latencyGroup),
+		lastPrune: time.Now(),
+	}
+}
+
+func (t *ReceiptTracker) group(key latencyKey) *latencyGroup {
+	g, ok := t.groups[key]
+	if !ok {
+		g = &latencyGroup{}
+		t.groups[key] = g
+	}
+	return g
+}
+
+// Prepare 登记一个批次中的交易 hash, 发送时由 Sent 记录发送时间
+func (t *ReceiptTracker) Prepare(shardID int, sequenceID int64, transactions []*types.Transaction, cstransactions []*types.CrossShardTransaction) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	batch := &pendingBatch{}
+	register := func(hash []byte, txType string) {
+		key := latencyKey{ShardID: shardID, Type: txType}
+		encoded := hex.EncodeToString(hash)
+		t.pending[encoded] = submission{key: key}
+		batch.hashes = append(batch.hashes, encoded)
+		batch.keys = append(batch.keys, key)
+	}
+	for _, tx := range transactions {
+		register(tx.Hash, TxIntra)
+	}
+	for _, ctx := range cstransactions {
+		register(ctx.Hash, TxCrossShard)
+	}
+	t.batches[batchKey{ShardID: shardID, SequenceID: sequenceID}] = batch
+}
+
+// Sent 在发送批次之前记录发送时间
+// NOTE: shard 可能在发送请求返回之前就回调回执, 因此不能等到发送成功后再记录
+func (t *ReceiptTracker) Sent(shardID int, sequenceID int64, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	batch, ok := t.batches[batchKey{ShardID: shardID, SequenceID: sequenceID}]
+	if !ok {
+		return
+	}
+	batch.sentAt = at
+	for _, hash := range batch.hashes {
+		if sub, ok := t.pending[hash]; ok {
+			sub.at = at
+			t.pending[hash] = sub
+		}
+	}
+}
+
+// Done 记录批次的发送结果, 发送失败的批次不再等待回执
+func (t *ReceiptTracker) Done(shardID int, sequenceID int64, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := batchKey{ShardID: shardID, SequenceID: sequenceID}
+	batch, ok := t.batches[key]
+	if !ok {
+		return
+	}
+	delete(t.batches, key)
+	for i, hash := range batch.hashes {
+		if err != nil {
+			delete(t.pending, hash)
+			continue
+		}
+		// NOTE: 回执可能先于发送结果到达, 此时交易已经不在 pending 中, 但仍然计入发送数
+		g := t.group(batch.keys[i])
+		g.submitted++
+		if g.firstSubmitted.IsZero() || batch.sentAt.Before(g.firstSubmitted) {
+			g.firstSubmitted = batch.sentAt
+		}
+	}
+	now := time.Now()
+	if now.Sub(t.lastPrune) > time.Minute {
+		t.prune(now)
+	}
+}
+
+// Forget 丢弃一个不会被发送的批次 (例如离线生成的批次)
+func (t *ReceiptTracker) Forget(shardID int, sequenceID int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := batchKey{ShardID: shardID, SequenceID: sequenceID}
+	if batch, ok := t.batches[key]; ok {
+		for _, hash := range batch.hashes {
+			delete(t.pending, hash)
+		}
+		delete(t.batches, key)
+	}
+}
+
+// prune 丢弃超时未确认的交易, 调用方需持有 t.mu
+func (t *ReceiptTracker) prune(now time.Time) {
+	for hash, sub := range t.pending {
+		if !sub.at.IsZero() && now.Sub(sub.at) > receiptTimeout {
+			t.group(sub.key).expired++
+			delete(t.pending, hash)
+		}
+	}
+	t.lastPrune = now
+}
+
+// Record 将回执与发送记录匹配, 返回是否匹配成功; 重复或未知的回执计入 unmatched
+func (t *ReceiptTracker) Record(receipt types.Receipt, received time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	hash := hex.EncodeToString(receipt.Hash)
+	sub, ok := t.pending[hash]
+	if !ok || sub.at.IsZero() {
+		t.unmatched++
+		return false
+	}
+	delete(t.pending, hash)
+	committed := received
+	if receipt.CommittedAt > 0 {
+		committed = time.Unix(0, receipt.CommittedAt)
+	}
+	g := t.group(sub.key)
+	if !receipt.Status {
+		g.failed++
+		return true
+	}
+	g.confirmed++
+	g.add(committed.Sub(sub.at))
+	if committed.After(g.lastCommitted) {
+		g.lastCommitted = committed
+	}
+	return true
+}
+
+// LatencyStats 是一组交易 (按 shard 与类型划分) 的延迟分位数与确认吞吐量, 延迟单位为毫秒
+type LatencyStats struct {
+	ShardID      int     `json:"shard_id"`
+	Type         string  `json:"type"`
+	Submitted    int     `json:"submitted"`
+	Confirmed    int     `json:"confirmed"`
+	Failed       int     `json:"failed"`
+	Pending      int     `json:"pending"`
+	Expired      int     `json:"expired"`
+	Mean         float64 `json:"mean_ms"`
+	P50          float64 `json:"p50_ms"`
+	P90          float64 `json:"p90_ms"`
+	P99          float64 `json:"p99_ms"`
+	Max          float64 `json:"max_ms"`
+	ConfirmedTPS float64 `json:"confirmed_tps"`
+}
+
+type LatencyReport struct {
+	Groups    []LatencyStats `json:"groups"`
+	Unmatched int            `json:"unmatched"`
+}
+
+func (t *ReceiptTracker) Report() LatencyReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pending := make(map[latencyKey]int)
+	for _, sub := range t.pending {
+		if !sub.at.IsZero() {
+			pending[sub.key]++
+		}
+	}
+	report := LatencyReport{Groups: make([]LatencyStats, 0, len(t.groups)), Unmatched: t.unmatched}
+	for key, g := range t.groups {
+		stats := LatencyStats{
+			ShardID:   key.ShardID,
+			Type:      key.Type,
+			Submitted: g.submitted,
+			Confirmed: g.confirmed,
+			Failed:    g.failed,
+			Pending:   pending[key],
+			Expired:   g.expired,
+		}
+		if len(g.samples) > 0 {
+			samples := append([]time.Duration(nil), g.samples...)
+			sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+			var sum time.Duration
+			for _, sample := range samples {
+				sum += sample
+			}
+			stats.Mean = milliseconds(sum / time.Duration(len(samples)))
+			stats.P50 = milliseconds(percentile(samples, 0.50))
+			stats.P90 = milliseconds(percentile(samples, 0.90))
+			stats.P99 = milliseconds(percentile(samples, 0.99))
+			stats.Max = milliseconds(samples[len(samples)-1])
+		}
+		if window := g.lastCommitted.Sub(g.firstSubmitted).Seconds(); g.confirmed > 0 && window > 0 {
+			stats.ConfirmedTPS = float64(g.confirmed) / window
+		}
+		report.Groups = append(report.Groups, stats)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		if report.Groups[i].ShardID != report.Groups[j].ShardID {
+			return report.Groups[i].ShardID < report.Groups[j].ShardID
+		}
+		return report.Groups[i].Type < report.Groups[j].Type
+	})
+	return report
+}
+
+// percentile 使用最近秩法, samples 需已排序
+func percentile(samples []time.Duration, p float64) time.Duration {
+	rank := int(p*float64(len(samples))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(samples) {
+		rank = len(samples) - 1
+	}
+	return samples[rank]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// handleReceipts 接收 shard 回调的提交回执, 请求体为 Receipt 数组
+func (s *Server) handleReceipts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var receipts []types.Receipt
+	if err := json.NewDecoder(r.Body).Decode(&receipts); err != nil {
+		http.Error(w, "Invalid receipts: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	received := time.Now()
+	result := struct {
+		Matched   int `json:"matched"`
+		Unmatched int `json:"unmatched"`
+	}{}
+	for _, receipt := range receipts {
+		if s.Receipts.Record(receipt, received) {
+			result.Matched++
+		} else {
+			result.Unmatched++
+		}
+	}
+	writeJSON(w, result)
+}
+
+func (s *Server) handleLatency(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, s.Receipts.Report())
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"errors"
+	"generator_boilerplate/types"
+	"testing"
+	"time"
+)
+
+func TestReceiptTracker(t *testing.T) {
+	tracker := NewReceiptTracker()
+	intra := &types.Transaction{Hash: []byte{1}}
+	cross := &types.CrossShardTransaction{Hash: []byte{2}}
+	sent := time.Unix(100, 0)
+
+	tracker.Prepare(0, 1, []*types.Transaction{intra}, []*types.CrossShardTransaction{cross})
+	tracker.Sent(0, 1, sent)
+	// NOTE: 回执先于发送结果到达
+	if !tracker.Record(types.Receipt{Status: true, Hash: intra.Hash, CommittedAt: sent.Add(30 * time.Millisecond).UnixNano()}, time.Now()) {
+		t.Fatal("receipt of a sent transaction should match")
+	}
+	tracker.Done(0, 1, nil)
+	if !tracker.Record(types.Receipt{Status: false, Hash: cross.Hash}, sent.Add(time.Second)) {
+		t.Fatal("failed receipt should still match")
+	}
+	if tracker.Record(types.Receipt{Status: true, Hash: intra.Hash}, time.Now()) {
+		t.Fatal("duplicate receipt should not match")
+	}
+
+	tracker.Prepare(1, 2, []*types.Transaction{{Hash: []byte{3}}}, nil)
+	tracker.Sent(1, 2, sent)
+	tracker.Done(1, 2, errors.New("unreachable"))
+	if tracker.Record(types.Receipt{Status: true, Hash: []byte{3}}, time.Now()) {
+		t.Fatal("receipt of a failed batch should not match")
+	}
+
+	report := tracker.Report()
+	if report.Unmatched != 2 || len(report.Groups) != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	crossStats, intraStats := report.Groups[0], report.Groups[1]
+	if crossStats.Type != TxCrossShard || crossStats.Failed != 1 || crossStats.Confirmed != 0 {
+		t.Fatalf("unexpected cross shard stats %+v", crossStats)
+	}
+	if intraStats.Submitted != 1 || intraStats.Confirmed != 1 || intraStats.P50 != 30 || intraStats.Pending != 0 {
+		t.Fatalf("unexpected intra stats %+v", intraStats)
+	}
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"generator_boilerplate/metrics"
+	"strconv"
+)
+
+// NOTE: 批次大小 (交易数) 的分桶
+var batchSizeBuckets = []float64{1, 5, 10, 20, 50, 100, 200, 500, 1000, 5000}
+
+// Metrics 是生成器暴露给 Prometheus 的指标
+type Metrics struct {
+	Registry *metrics.Registry
+
+	accounts     *metrics.Counter
+	transactions *metrics.Counter
+	rejections   *metrics.Counter
+	batchSize    *metrics.Histogram
+	requests     *metrics.Counter
+	duration     *metrics.Histogram
+	retries      *metrics.Counter
+}
+
+func NewMetrics() *Metrics {
+	registry := metrics.NewRegistry()
+	return &Metrics{
+		Registry:     registry,
+		accounts:     registry.NewCounter("generator_accounts_generated_total", "Accounts generated per shard.", "shard"),
+		transactions: registry.NewCounter("generator_transactions_generated_total", "Transactions generated per shard and type.", "shard", "type"),
+		rejections:   registry.NewCounter("generator_rejections_total", "Transactions the generator refused to generate, by reason.", "shard", "reason"),
+		batchSize:    registry.NewHistogram("generator_batch_size", "Number of transactions per batch sent to a shard.", batchSizeBuckets, "shard"),
+		requests:     registry.NewCounter("generator_shard_requests_total", "HTTP requests sent to shard leaders, by path and status code.", "shard", "path", "code"),
+		duration:     registry.NewHistogram("generator_shard_request_duration_seconds", "Latency of HTTP requests sent to shard leaders.", metrics.DefBuckets, "shard", "path"),
+		retries:      registry.NewCounter("generator_shard_retries_total", "Retries of failed HTTP requests sent to shard leaders.", "shard", "path"),
+	}
+}
+
+func shardLabel(shardID int) string {
+	return strconv.Itoa(shardID)
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"generator_boilerplate/types"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// NOTE: 迁移后源 shard 至少保留的账户数, 保证仍然可以生成片内交易
+const minShardAccounts = 2
+
+var errNoMigration = errors.New("no shard pair to migrate accounts between")
+
+// MigrationConfig 描述迁移 job 的源与目标 shard, -1 表示每次随机选择
+type MigrationConfig struct {
+	FromShard int
+	ToShard   int
+}
+
+// migrateAccounts 选择一批账户, 请求源 shard 迁移它们, 成功后更新生成器自己的账户视图
+func (s *Server) migrateAccounts(job *Job) (types.MigrationMsg, error) {
+	msg := types.MigrationMsg{}
+	view, _ := s.partitionView()
+	from, to, err := s.migrationPair(job, view)
+	if err != nil {
+		return msg, err
+	}
+	candidates := view[from]
+	number := job.Number()
+	if limit := len(candidates) - minShardAccounts; number > limit {
+		number = limit
+	}
+	accounts := make([]types.Account, 0, number)
+	picked := make(map[int]bool)
+	for attempts := 0; len(accounts) < number && attempts < 100*number; attempts++ {
+		i, err := job.Options.Selector.Pick(job.src, len(candidates))
+		if err != nil {
+			return msg, err
+		}
+		if !picked[i] {
+			picked[i] = true
+			accounts = append(accounts, candidates[i])
+		}
+	}
+
+	msg.Timestamp = time.Now().UnixNano()
+	msg.FromShard, msg.ToShard = from, to
+	msg.Codec = job.Codec
+	for _, acc := range s.Ledger.Snapshot(accounts) {
+		content, err := job.Codec.Encode(&acc)
+		if err != nil {
+			return msg, fmt.Errorf("encode account %s: %w", acc.Address, err)
+		}
+		msg.Accounts = append(msg.Accounts, content)
+	}
+	msg.AddressNumber = len(accounts)
+	msg.SequenceID = s.nextSequenceID(from)
+
+	if err := s.submitMigration(msg); err != nil {
+		return msg, err
+	}
+	s.applyMigration(accounts, from, to)
+	return msg, nil
+}
+
+// migrationPair 返回本次迁移的源与目标 shard, 未指定时随机选择
+func (s *Server) migrationPair(job *Job, view map[int][]types.Account) (int, int, error) {
+	from, to := job.Migration.FromShard, job.Migration.ToShard
+	// NOTE: 目标 shard 被注销后不再迁移, 否则迁移过去的账户之后的交易都无法送达
+	if to >= 0 {
+		if _, err := s.Shards.Leader(to); err != nil {
+			return 0, 0, err
+		}
+	}
+	sources := make([]int, 0)
+	for _, id := range s.Shards.IDs() {
+		if len(view[id]) > minShardAccounts && (from < 0 || id == from) && id != to {
+			sources = append(sources, id)
+		}
+	}
+	if len(sources) == 0 {
+		return 0, 0, errNoMigration
+	}
+	i, err := job.src.Intn(len(sources))
+	if err != nil {
+		return 0, 0, err
+	}
+	from = sources[i]
+	if to < 0 {
+		targets := make([]int, 0)
+		for _, id := range s.Shards.IDs() {
+			if id != from {
+				targets = append(targets, id)
+			}
+		}
+		if len(targets) == 0 {
+			return 0, 0, errNoMigration
+		}
+		if i, err = job.src.Intn(len(targets)); err != nil {
+			return 0, 0, err
+		}
+		to = targets[i]
+	}
+	return from, to, nil
+}
+
+// applyMigration 将账户从所在的 AddressMap 项移动到 to, 并重建划分视图, 之后的交易按照新的位置生成
+func (s *Server) applyMigration(accounts []types.Account, from, to int) {
+	for _, acc := range accounts {
+		if err := s.Ledger.Migrate(acc.Address, to); err != nil {
+			log.Printf("[ERROR] Failed to migrate %s in the ledger: %v", acc.Address, err)
+		}
+	}
+	changed, err := s.moveAccounts(accounts, to)
+	if err != nil {
+		log.Printf("[ERROR] Failed to build partitioner: %v", err)
+	}
+	for shardID := range changed {
+		s.saveShard(shardID)
+	}
+	log.Printf("Migrated %d accounts from shard %d to shard %d.", len(accounts), from, to)
+}
+
+// submitMigration 将迁移请求发送给源 shard 的 #0 节点
+func (s *Server) submitMigration(msg types.MigrationMsg) error {
+	jsonData, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal migration: %w", err)
+	}
+	resp, err := s.postToShard(msg.FromShard, "/migrate", jsonData)
+	if err != nil {
+		s.deadLetter(msg.SequenceID, jsonData, err)
+		return fmt.Errorf("send migration to shard %d: %w", msg.FromShard, err)
+	}
+	resp.Body.Close()
+	log.Printf("%d accounts migrating from shard %d to shard %d", msg.AddressNumber, msg.FromShard, msg.ToShard)
+	return nil
+}
+
+func (s *Server) handleMigrateAccounts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+	migration := &MigrationConfig{FromShard: -1, ToShard: -1}
+	for name, value := range map[string]*int{"shard_id": &migration.FromShard, "to": &migration.ToShard} {
+		if param := params.Get(name); param != "" {
+			shardID, err := strconv.Atoi(param)
+			if err != nil || shardID < 0 {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*value = shardID
+		}
+	}
+	if migration.FromShard >= 0 && migration.FromShard == migration.ToShard {
+		http.Error(w, "Source and target shard must differ", http.StatusBadRequest)
+		return
+	}
+	for _, shardID := range []int{migration.FromShard, migration.ToShard} {
+		if shardID < 0 {
+			continue
+		}
+		if _, err := s.Shards.Leader(shardID); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	}
+	src, err := s.source(params, migration.FromShard)
+	if err != nil {
+		http.Error(w, "Invalid seed", http.StatusBadRequest)
+		return
+	}
+	cfg, err := s.jobConfig(migration.FromShard, params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// NOTE: 每次默认迁移一个账户, 可以用 number 调整
+	if params.Get("number") == "" {
+		cfg.Number = 1
+	}
+	cfg.Mode, cfg.Migration = ModeMigration, migration
+
+	job, err := s.startJob(cfg, false, src)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	log.Printf("Started migration job %s for shard %d.", job.ID, migration.FromShard)
+	writeJSON(w, job.Status())
+}
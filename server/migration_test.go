@@ -0,0 +1,95 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMigrateAccountsEndpoint(t *testing.T) {
+	s, base, stubs := newTestServer(t, 2)
+	for shardID, number := range map[int]int{0: 5, 1: 3} {
+		if code := call(t, http.MethodPost, fmt.Sprintf("%s/generate_account?shard_id=%d&acc_number=%d&seed=1", base, shardID, number), nil); code != http.StatusOK {
+			t.Fatalf("generate accounts for shard %d: status %d", shardID, code)
+		}
+	}
+	for _, query := range []string{"shard_id=0&to=0", "shard_id=x", "to=-1"} {
+		if code := call(t, http.MethodPost, base+"/migrate_accounts?"+query, nil); code != http.StatusBadRequest {
+			t.Fatalf("%s: status %d", query, code)
+		}
+	}
+	for _, query := range []string{"shard_id=0&to=7", "shard_id=7&to=1"} {
+		if code := call(t, http.MethodPost, base+"/migrate_accounts?"+query, nil); code != http.StatusNotFound {
+			t.Fatalf("%s: status %d", query, code)
+		}
+	}
+	original := s.addressMap()[0]
+
+	// NOTE: 每次迁移 2 个账户, 源 shard 保留 minShardAccounts 个账户后不再迁移
+	var status JobStatus
+	if code := call(t, http.MethodPost, base+"/migrate_accounts?shard_id=0&to=1&number=2&interval=10ms", &status); code != http.StatusOK {
+		t.Fatalf("start migration: status %d", code)
+	}
+	if status.Mode != ModeMigration || status.TargetShard == nil || *status.TargetShard != 1 {
+		t.Fatalf("unexpected migration job %+v", status)
+	}
+	if code := call(t, http.MethodPost, base+"/migrate_accounts?shard_id=0&to=1", nil); code != http.StatusConflict {
+		t.Fatalf("second migration job on shard 0: status %d", code)
+	}
+	waitFor(t, "the migration to reach the account floor", func() bool {
+		call(t, http.MethodGet, base+"/jobs/status?id="+status.ID, &status)
+		return status.Failures > 0
+	})
+	if !strings.Contains(status.LastError, errNoMigration.Error()) || status.Migrated != 3 {
+		t.Fatalf("job after reaching the floor: %+v", status)
+	}
+
+	migrated := 0
+	moved := make(map[string]bool)
+	for _, msg := range stubs[0].receivedMigrations() {
+		if msg.FromShard != 0 || msg.ToShard != 1 {
+			t.Fatalf("migration sent for %d -> %d", msg.FromShard, msg.ToShard)
+		}
+		migrated += msg.AddressNumber
+	}
+	if migrated != 3 || len(stubs[1].receivedMigrations()) != 0 {
+		t.Fatalf("source shard received %d migrated accounts", migrated)
+	}
+
+	// NOTE: 提交后划分视图与账本都使用新的位置
+	view, _ := s.partitionView()
+	if len(view[0]) != minShardAccounts || len(view[1]) != 6 {
+		t.Fatalf("view has %d and %d accounts", len(view[0]), len(view[1]))
+	}
+	if entries := s.Ledger.Entries(0); len(entries) != minShardAccounts {
+		t.Fatalf("ledger keeps %d accounts in shard 0", len(entries))
+	}
+	remaining := make(map[string]bool)
+	for _, acc := range view[0] {
+		remaining[acc.Address] = true
+	}
+	for _, acc := range original {
+		if !remaining[acc.Address] {
+			moved[acc.Address] = true
+		}
+	}
+	if len(moved) != 3 {
+		t.Fatalf("%d accounts left shard 0, want 3", len(moved))
+	}
+	for _, entry := range s.Ledger.Entries(1) {
+		delete(moved, entry.Address)
+	}
+	if len(moved) != 0 {
+		t.Fatalf("migrated accounts missing from the ledger of shard 1: %v", moved)
+	}
+	for _, acc := range original {
+		var partition AddressPartition
+		if code := call(t, http.MethodGet, base+"/partition?address="+acc.Address, &partition); code != http.StatusOK {
+			t.Fatalf("partition of %s: status %d", acc.Address, code)
+		}
+		if want := map[bool]int{true: 0, false: 1}[remaining[acc.Address]]; partition.FromShard != want {
+			t.Fatalf("%s is placed in shard %d, want %d", acc.Address, partition.FromShard, want)
+		}
+	}
+}
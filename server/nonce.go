@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"generator_boilerplate/generator"
+	"net/http"
+	"strconv"
+)
+
+// NonceReport 汇总一次 nonce 同步的结果
+type NonceReport struct {
+	ShardID  int `json:"shard_id"`
+	Checked  int `json:"checked"`
+	Advanced int `json:"advanced"`
+	Rewound  int `json:"rewound"`
+}
+
+type noncesRequest struct {
+	Addresses []string `json:"addresses"`
+}
+
+type noncesResponse struct {
+	// NOTE: 地址 -> 该地址在 shard 上最近一笔已提交交易的 nonce
+	Nonces map[string]int64 `json:"nonces"`
+}
+
+// syncNonces 向 shard 的 #0 节点查询账户已提交的 nonce, 并按照 policy 校正账本
+func (s *Server) syncNonces(shardID int, policy generator.GapPolicy) (NonceReport, error) {
+	report := NonceReport{ShardID: shardID}
+	view, _ := s.partitionView()
+	request := noncesRequest{Addresses: make([]string, 0, len(view[shardID]))}
+	for _, acc := range view[shardID] {
+		request.Addresses = append(request.Addresses, acc.Address)
+	}
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return report, err
+	}
+	resp, err := s.postToShard(shardID, "/nonces", jsonData)
+	if err != nil {
+		return report, fmt.Errorf("query nonces of shard %d: %w", shardID, err)
+	}
+	defer resp.Body.Close()
+	response := noncesResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return report, fmt.Errorf("decode nonces of shard %d: %w", shardID, err)
+	}
+
+	for address, committed := range response.Nonces {
+		before, after := s.Ledger.ReconcileNonce(address, committed, policy)
+		report.Checked++
+		switch {
+		case after > before:
+			report.Advanced++
+		case after < before:
+			report.Rewound++
+		}
+	}
+	return report, nil
+}
+
+func (s *Server) handleSyncNonces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+	shardID, err := strconv.Atoi(params.Get("shard_id"))
+	if err != nil {
+		http.Error(w, "Invalid shard_id", http.StatusBadRequest)
+		return
+	}
+	policy := s.Config.NonceGapPolicy
+	if param := params.Get("policy"); param != "" {
+		policy = param
+	}
+	gapPolicy, err := generator.ParseGapPolicy(policy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report, err := s.syncNonces(shardID, gapPolicy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	s.saveShard(shardID)
+	writeJSON(w, report)
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"generator_boilerplate/generator"
+	"generator_boilerplate/types"
+	"net/url"
+	"time"
+)
+
+// GenerateAccounts 按照参数 (seed / mnemonic) 与划分策略为 shard 生成 number 个账户, 不修改 Server 的状态
+// NOTE: 除了按照账户归属划分以外, 只保留在划分策略下属于该 shard 的账户
+func (s *Server) GenerateAccounts(shardID, number int, params url.Values) ([]types.Account, error) {
+	partitioned := s.Config.Partitioner != "" && s.Config.Partitioner != generator.PartitionAccount
+	_, partitioner := s.partitionView()
+	mnemonic := s.mnemonic(params)
+	var (
+		accounts []types.Account
+		err      error
+	)
+	switch {
+	case mnemonic != "" && partitioned:
+		accounts, err = generator.DeriveShardAccounts(mnemonic, partitioner, shardID, number, s.Config.Balance)
+	case mnemonic != "":
+		accounts, err = generator.DeriveAccounts(mnemonic, shardID, number, s.Config.Balance)
+	default:
+		var src *generator.Source
+		if src, err = s.source(params, shardID); err != nil {
+			return nil, fmt.Errorf("%w: seed %q", errInvalidParam, params.Get("seed"))
+		}
+		if partitioned {
+			accounts, err = generator.GenerateShardAccounts(src, partitioner, shardID, number, s.Config.Balance)
+		} else {
+			accounts, err = generator.GenerateAccounts(src, number, s.Config.Balance)
+		}
+	}
+	if errors.Is(err, generator.ErrPartitionUnreachable) {
+		return nil, fmt.Errorf("partitioner %s does not assign addresses to shard %d: %w", partitioner, shardID, err)
+	}
+	return accounts, err
+}
+
+// SetAccounts 替换 shard 的账户 (例如从数据集文件中读取), 同时更新账本与划分视图
+func (s *Server) SetAccounts(shardID int, accounts []types.Account) error {
+	s.Ledger.SetAccounts(shardID, accounts)
+	return s.setShardAccounts(shardID, accounts)
+}
+
+// BatchGenerator 离线为一个 shard 依次生成交易批次, 参数与 /generate_transaction 相同
+// NOTE: 批次的 Timestamp 按照 interval 排列, 回放时可以据此还原发送时间
+type BatchGenerator struct {
+	s     *Server
+	job   *Job
+	start time.Time
+	next  int
+}
+
+func (s *Server) NewBatchGenerator(shardID int, params url.Values) (*BatchGenerator, error) {
+	src, err := s.source(params, shardID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: seed %q", errInvalidParam, params.Get("seed"))
+	}
+	cfg, err := s.jobConfig(shardID, params)
+	if err != nil {
+		return nil, err
+	}
+	job := newJob(fmt.Sprintf("offline-%d", shardID), cfg, src)
+	// NOTE: 离线生成不需要定时器
+	job.ticker.Stop()
+	start := time.Now()
+	if src.Deterministic() {
+		// NOTE: 确定性模式下从零时刻开始排列, 保证相同 seed 生成的文件字节一致
+		start = time.Unix(0, 0)
+	}
+	return &BatchGenerator{s: s, job: job, start: start}, nil
+}
+
+// Next 生成下一个批次, 批次大小超出账户能力时按照 infeasible 参数处理
+func (g *BatchGenerator) Next() (types.RequestMsg, error) {
+	msg, err := g.s.generateBatch(g.job)
+	if err != nil {
+		return msg, err
+	}
+	// NOTE: 不会发送, 也就不会有回执
+	g.s.Receipts.Forget(g.job.ShardID, msg.SequenceID)
+	msg.Timestamp = g.start.Add(time.Duration(g.next) * g.job.Interval()).UnixNano()
+	g.next++
+	return msg, nil
+}
+
+// Status 返回生成过程的统计, 例如部分批次数与按原因统计的拒绝数
+func (g *BatchGenerator) Status() JobStatus {
+	return g.job.Status()
+}
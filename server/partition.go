@@ -0,0 +1,51 @@
+package server
+
+import (
+	"generator_boilerplate/generator"
+	"net/http"
+	"sort"
+)
+
+// PartitionState 描述当前的划分策略, 以及每个 shard 在该策略下拥有的账户数
+type PartitionState struct {
+	Strategy string       `json:"strategy"`
+	Shards   []ShardState `json:"shards"`
+}
+
+// AddressPartition 是地址在当前划分策略下的归属, 同时给出 from/to 时说明是否为跨片交易
+type AddressPartition struct {
+	Strategy   string `json:"strategy"`
+	From       string `json:"from"`
+	FromShard  int    `json:"from_shard"`
+	To         string `json:"to,omitempty"`
+	ToShard    *int   `json:"to_shard,omitempty"`
+	CrossShard *bool  `json:"cross_shard,omitempty"`
+}
+
+func (s *Server) handlePartition(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+	from := params.Get("address")
+	if from == "" {
+		from = params.Get("from")
+	}
+	view, partitioner := s.partitionView()
+	if from == "" {
+		state := PartitionState{Strategy: partitioner.String(), Shards: make([]ShardState, 0, len(view))}
+		for shardID, accounts := range view {
+			state.Shards = append(state.Shards, ShardState{ShardID: shardID, Accounts: len(accounts)})
+		}
+		sort.Slice(state.Shards, func(i, j int) bool { return state.Shards[i].ShardID < state.Shards[j].ShardID })
+		writeJSON(w, state)
+		return
+	}
+	result := AddressPartition{Strategy: partitioner.String(), From: from, FromShard: partitioner.Shard(from)}
+	if to := params.Get("to"); to != "" {
+		toShard, crossShard := partitioner.Shard(to), generator.CrossShard(partitioner, from, to)
+		result.To, result.ToShard, result.CrossShard = to, &toShard, &crossShard
+	}
+	writeJSON(w, result)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"generator_boilerplate/generator"
+	"log"
+	"sync"
+	"time"
+)
+
+// maxInflightBatches 是 poisson job 同时在发送中的批次数的上限
+var maxInflightBatches = 64
+
+// saveInterval 是高速率 job (poisson, 数据集回放) 持久化账本的最小间隔
+const saveInterval = time.Second
+
+// poissonInterval 返回下一次到达的间隔: 每次到达发送 number 笔交易, 平均每秒 tps 笔
+func poissonInterval(src *generator.Source, tps float64, number int) time.Duration {
+	rate := tps / float64(number)
+	return time.Duration(src.ExpFloat64() / rate * float64(time.Second))
+}
+
+// runPoissonJob 以开环方式发送交易: 到达间隔服从均值为 batch/TPS 的指数分布,
+// 每次到达生成一个小批次并异步发送, 发送的快慢不会影响后续到达的时间, 同时发送中的批次不超过 maxInflightBatches
+func (s *Server) runPoissonJob(job *Job) {
+	var inflight sync.WaitGroup
+	defer inflight.Wait()
+	slots := make(chan struct{}, maxInflightBatches)
+
+	// NOTE: 高 TPS 下不能每次到达都落盘, 按 saveInterval 节流
+	defer s.saveJobShards(job)
+	lastSave := time.Now()
+	s.syncJobNonces(job)
+	lastSync := time.Now()
+
+	next := time.Now()
+	for {
+		// NOTE: 以绝对时间推进下一次到达, 避免生成和调度的耗时累积成速率偏差
+		next = next.Add(poissonInterval(job.src, job.TPS(), job.Number()))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-job.done:
+			timer.Stop()
+			log.Printf("Job %s for shard %d stopped.", job.ID, job.ShardID)
+			return
+		case <-timer.C:
+		}
+		if job.State() != JobRunning {
+			// NOTE: 暂停期间不累积到达, 恢复后从当前时间重新开始
+			next = time.Now()
+			continue
+		}
+		if err := s.checkSourceShard(job); err != nil {
+			continue
+		}
+		if time.Since(lastSync) >= job.Interval() {
+			s.syncJobNonces(job)
+			lastSync = time.Now()
+		}
+		select {
+		case slots <- struct{}{}:
+		default:
+			// NOTE: 发送跟不上到达速率时丢弃本次到达并记为 skipped, 既不阻塞后续到达, 也不在账本中记录不会发送的交易
+			job.recordSkipped(job.Number())
+			continue
+		}
+		msg, err := s.generateBatch(job)
+		if err != nil {
+			<-slots
+			log.Printf("[ERROR] Job %s: %v", job.ID, err)
+			job.recordFailure(err)
+			continue
+		}
+		if time.Since(lastSave) >= saveInterval {
+			s.saveJobShards(job)
+			lastSave = time.Now()
+		}
+		inflight.Add(1)
+		go func() {
+			defer inflight.Done()
+			defer func() { <-slots }()
+			err := s.submitBatch(job.ShardID, msg)
+			if err != nil {
+				log.Printf("[ERROR] Job %s: %v", job.ID, err)
+			}
+			job.recordBatch(msg, err)
+		}()
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"generator_boilerplate/config"
+	"generator_boilerplate/generator"
+	"generator_boilerplate/store"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPoissonRate(t *testing.T) {
+	// NOTE: 使用固定种子的随机源模拟到达, 不依赖真实时钟
+	src := generator.NewSource(7)
+	const tps, number, arrivals = 500.0, 5, 20000
+	var elapsed time.Duration
+	for i := 0; i < arrivals; i++ {
+		elapsed += poissonInterval(src, tps, number)
+	}
+	achieved := float64(arrivals*number) / elapsed.Seconds()
+	if math.Abs(achieved-tps)/tps > 0.03 {
+		t.Fatalf("achieved %.1f TPS, target %.1f", achieved, tps)
+	}
+}
+
+// blockingShard 在 release 关闭之前阻塞所有请求, 并记录同时在处理中的请求数的最大值
+type blockingShard struct {
+	release chan struct{}
+
+	mu          sync.Mutex
+	inflight    int
+	maxInflight int
+}
+
+func (b *blockingShard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	b.inflight++
+	b.maxInflight = max(b.maxInflight, b.inflight)
+	b.mu.Unlock()
+	<-b.release
+	b.mu.Lock()
+	b.inflight--
+	b.mu.Unlock()
+}
+
+func TestPoissonInflightLimit(t *testing.T) {
+	defer func(limit int) { maxInflightBatches = limit }(maxInflightBatches)
+	maxInflightBatches = 2
+
+	shard := &blockingShard{release: make(chan struct{})}
+	ts := httptest.NewServer(shard)
+	defer ts.Close()
+	released := false
+	release := func() {
+		if !released {
+			close(shard.release)
+			released = true
+		}
+	}
+	defer release()
+
+	cfg := config.Default()
+	cfg.ShardsTable = map[string]string{"Shard_0": ts.URL, "Shard_1": ts.URL}
+	s := NewServer(cfg, store.NewMemoryStore())
+	accounts, err := generator.GenerateAccounts(generator.NewSource(1), 20, cfg.Balance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetAccounts(0, accounts); err != nil {
+		t.Fatal(err)
+	}
+	jobCfg, err := s.jobConfig(0, map[string][]string{"mode": {"poisson"}, "tps": {"2000"}, "number": {"1"}, "cross_shard_ratio": {"0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := s.startJob(jobCfg, false, generator.NewSource(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	release()
+	_ = job.Stop()
+
+	shard.mu.Lock()
+	maxInflight := shard.maxInflight
+	shard.mu.Unlock()
+	if maxInflight == 0 || maxInflight > 2 {
+		t.Fatalf("%d batches in flight, limit 2", maxInflight)
+	}
+	if status := job.Status(); status.Skipped == 0 {
+		t.Fatalf("arrivals beyond the in-flight limit were not skipped: %+v", status)
+	}
+}
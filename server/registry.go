@@ -0,0 +1,151 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ShardInfo 描述一个 shard 的 #0 节点 (leader) 以及可选的副本节点
+type ShardInfo struct {
+	ID       int      `json:"shard_id"`
+	Leader   string   `json:"leader"`
+	Replicas []string `json:"replicas,omitempty"`
+}
+
+// Registry 记录当前的 shard 拓扑, 注册/注销会立即影响正在运行的 job
+type Registry struct {
+	mu     sync.RWMutex
+	shards map[int]ShardInfo
+}
+
+// NewRegistry 以 "Shard_%d" -> leader URL 形式的静态表初始化拓扑
+func NewRegistry(table map[string]string) (*Registry, error) {
+	registry := &Registry{shards: make(map[int]ShardInfo, len(table))}
+	for name, leader := range table {
+		id, err := strconv.Atoi(strings.TrimPrefix(name, "Shard_"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid shard name %q", name)
+		}
+		registry.shards[id] = ShardInfo{ID: id, Leader: leader}
+	}
+	return registry, nil
+}
+
+func (r *Registry) Register(info ShardInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.shards[info.ID] = info
+}
+
+func (r *Registry) Deregister(id int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.shards[id]
+	delete(r.shards, id)
+	return ok
+}
+
+func (r *Registry) Get(id int) (ShardInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	info, ok := r.shards[id]
+	return info, ok
+}
+
+// Leader 返回 shard 的 leader URL
+func (r *Registry) Leader(id int) (string, error) {
+	info, ok := r.Get(id)
+	if !ok {
+		return "", fmt.Errorf("shard %d is not registered", id)
+	}
+	return info.Leader, nil
+}
+
+// IDs 返回按升序排列的 shard ID
+func (r *Registry) IDs() []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]int, 0, len(r.shards))
+	for id := range r.shards {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (r *Registry) List() []ShardInfo {
+	ids := r.IDs()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	shards := make([]ShardInfo, 0, len(ids))
+	for _, id := range ids {
+		if info, ok := r.shards[id]; ok {
+			shards = append(shards, info)
+		}
+	}
+	return shards
+}
+
+func (s *Server) handleRegisterShard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+	shardID, err := strconv.Atoi(params.Get("shard_id"))
+	if err != nil {
+		http.Error(w, "Invalid shard_id", http.StatusBadRequest)
+		return
+	}
+	leader := params.Get("leader")
+	if leader == "" {
+		http.Error(w, "Missing leader", http.StatusBadRequest)
+		return
+	}
+	info := ShardInfo{ID: shardID, Leader: leader}
+	for _, replica := range strings.Split(params.Get("replicas"), ",") {
+		if replica = strings.TrimSpace(replica); replica != "" {
+			info.Replicas = append(info.Replicas, replica)
+		}
+	}
+	s.Shards.Register(info)
+	if err := s.refreshPartition(); err != nil {
+		log.Printf("[ERROR] Failed to build partitioner: %v", err)
+	}
+	log.Printf("Shard %d registered with leader %s", shardID, leader)
+	writeJSON(w, info)
+}
+
+func (s *Server) handleDeregisterShard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	shardID, err := strconv.Atoi(r.URL.Query().Get("shard_id"))
+	if err != nil {
+		http.Error(w, "Invalid shard_id", http.StatusBadRequest)
+		return
+	}
+	if !s.Shards.Deregister(shardID) {
+		http.Error(w, fmt.Sprintf("Shard %d is not registered", shardID), http.StatusNotFound)
+		return
+	}
+	if err := s.refreshPartition(); err != nil {
+		log.Printf("[ERROR] Failed to build partitioner: %v", err)
+	}
+	log.Printf("Shard %d deregistered", shardID)
+	writeJSON(w, s.Shards.List())
+}
+
+func (s *Server) handleListShards(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, s.Shards.List())
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// waitFor 轮询 cond 直到其返回 true, 超时后测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRegistryEndpoints(t *testing.T) {
+	_, base, stubs := newTestServer(t, 1)
+	if code := call(t, http.MethodPost, base+"/generate_account?shard_id=0&acc_number=10&seed=1", nil); code != http.StatusOK {
+		t.Fatalf("generate accounts: status %d", code)
+	}
+	var shards []ShardInfo
+	if code := call(t, http.MethodGet, base+"/shards", &shards); code != http.StatusOK || len(shards) != 1 || shards[0].ID != 0 {
+		t.Fatalf("list shards: status %d, %+v", code, shards)
+	}
+	for query, want := range map[string]int{
+		"/register_shard?shard_id=1":          http.StatusBadRequest,
+		"/register_shard?shard_id=x&leader=l": http.StatusBadRequest,
+		"/deregister_shard?shard_id=7":        http.StatusNotFound,
+		"/deregister_shard?shard_id=x":        http.StatusBadRequest,
+	} {
+		if code := call(t, http.MethodPost, base+query, nil); code != want {
+			t.Fatalf("%s: status %d, want %d", query, code, want)
+		}
+	}
+
+	other := &shardStub{}
+	ts := httptest.NewServer(other)
+	defer ts.Close()
+	var info ShardInfo
+	if code := call(t, http.MethodPost, base+"/register_shard?shard_id=1&leader="+url.QueryEscape(ts.URL)+"&replicas=r1,%20r2", &info); code != http.StatusOK {
+		t.Fatalf("register shard: status %d", code)
+	}
+	if info.ID != 1 || info.Leader != ts.URL || len(info.Replicas) != 2 || info.Replicas[1] != "r2" {
+		t.Fatalf("registered %+v", info)
+	}
+	if code := call(t, http.MethodGet, base+"/shards", &shards); code != http.StatusOK || len(shards) != 2 || shards[1].ID != 1 {
+		t.Fatalf("list shards after register: status %d, %+v", code, shards)
+	}
+	if code := call(t, http.MethodPost, base+"/generate_account?shard_id=1&acc_number=10&seed=2", nil); code != http.StatusOK {
+		t.Fatalf("generate accounts for the registered shard: status %d", code)
+	}
+
+	// NOTE: 正在运行的 job 在下一个批次使用新注册的 shard 作为跨片交易的目标
+	var status JobStatus
+	if code := call(t, http.MethodPost, base+"/generate_transaction?shard_id=0&interval=20ms&number=5&cross_shard_ratio=100", &status); code != http.StatusOK {
+		t.Fatalf("start job: status %d", code)
+	}
+	crossShard := func(msg int) bool { return len(stubs[0].receivedRequests()[msg].CrossShardTransactions) > 0 }
+	waitFor(t, "a cross-shard batch", func() bool {
+		requests := stubs[0].receivedRequests()
+		return len(requests) > 0 && crossShard(len(requests)-1)
+	})
+
+	// NOTE: 注销后 job 继续运行, 之后的批次只包含片内交易
+	if code := call(t, http.MethodPost, base+"/deregister_shard?shard_id=1", &shards); code != http.StatusOK || len(shards) != 1 {
+		t.Fatalf("deregister shard: status %d, %+v", code, shards)
+	}
+	// NOTE: 注销时正在生成的批次可能仍然使用旧的拓扑, 跳过它
+	sent := len(stubs[0].receivedRequests())
+	waitFor(t, "batches after deregistration", func() bool { return len(stubs[0].receivedRequests()) > sent+1 })
+	if last := len(stubs[0].receivedRequests()) - 1; crossShard(last) {
+		t.Fatal("batch after deregistration targets the deregistered shard")
+	}
+	if code := call(t, http.MethodGet, base+"/jobs/status?id="+status.ID, &status); code != http.StatusOK || status.State != JobRunning {
+		t.Fatalf("job after deregistration: status %d, %s", code, status.State)
+	}
+}
+
+func TestDeregisterSourceShard(t *testing.T) {
+	s, base, stubs := newTestServer(t, 1)
+	// NOTE: 未注册的 shard 直接返回 404, 不会生成或者持久化账户
+	if code := call(t, http.MethodPost, base+"/generate_account?shard_id=7&acc_number=10&seed=1", nil); code != http.StatusNotFound {
+		t.Fatalf("generate accounts for an unregistered shard: status %d", code)
+	}
+	if loaded, err := s.Store.Load(); err != nil || len(loaded[7]) != 0 || len(s.Ledger.Entries(7)) != 0 {
+		t.Fatalf("accounts of an unregistered shard were stored: %v", err)
+	}
+	if code := call(t, http.MethodPost, base+"/generate_account?shard_id=0&acc_number=10&seed=1", nil); code != http.StatusOK {
+		t.Fatalf("generate accounts: status %d", code)
+	}
+	if code := call(t, http.MethodPost, base+"/generate_transaction?shard_id=7", nil); code != http.StatusNotFound {
+		t.Fatalf("start a job for an unregistered shard: status %d", code)
+	}
+
+	var status JobStatus
+	if code := call(t, http.MethodPost, base+"/generate_transaction?shard_id=0&interval=20ms&number=5", &status); code != http.StatusOK {
+		t.Fatalf("start job: status %d", code)
+	}
+	waitFor(t, "a batch", func() bool { return len(stubs[0].receivedRequests()) > 0 })
+	if code := call(t, http.MethodPost, base+"/deregister_shard?shard_id=0", nil); code != http.StatusOK {
+		t.Fatalf("deregister shard: status %d", code)
+	}
+	failures := func() int {
+		if code := call(t, http.MethodGet, base+"/jobs/status?id="+status.ID, &status); code != http.StatusOK {
+			t.Fatalf("job status: status %d", code)
+		}
+		return status.Failures
+	}
+	nonces := func() int64 {
+		var sum int64
+		for _, entry := range s.Ledger.Entries(0) {
+			sum += entry.Nonce
+		}
+		return sum
+	}
+	// NOTE: 注销时正在生成的批次已经记入账本, 从第一次失败之后开始检查
+	waitFor(t, "a failed batch", func() bool { return failures() > 0 })
+	before, failed := nonces(), failures()
+	waitFor(t, "more failed batches", func() bool { return failures() >= failed+3 })
+	if after := nonces(); after != before {
+		t.Fatalf("batches for a deregistered shard consumed %d nonces", after-before)
+	}
+
+	// NOTE: shard 重新注册后 job 继续发送
+	sent := len(stubs[0].receivedRequests())
+	if code := call(t, http.MethodPost, base+"/register_shard?shard_id=0&leader="+url.QueryEscape(s.Config.ShardsTable["Shard_0"]), nil); code != http.StatusOK {
+		t.Fatalf("register shard: status %d", code)
+	}
+	waitFor(t, "batches after re-registration", func() bool { return len(stubs[0].receivedRequests()) > sent })
+}
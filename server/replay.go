@@ -0,0 +1,333 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"generator_boilerplate/dataset"
+	"generator_boilerplate/generator"
+	"generator_boilerplate/types"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DatasetReplay 描述一次数据集 (由 accounts / txs 子命令生成) 的回放
+// NOTE: 所有批次在开始发送之前就已经读入内存并解析, 发送循环只负责按时间投递原始请求体
+type DatasetReplay struct {
+	Dir      string
+	Manifest dataset.Manifest
+	// NOTE: 每个 shard 每秒发送的批次数, 为 0 时按照批次记录的 Timestamp 发送
+	Rate float64
+	// NOTE: 按照 Timestamp 发送时, 批次间隔被除以 Speedup
+	Speedup float64
+	// NOTE: 是否先把账户发送给 shard
+	SendAccounts bool
+
+	accounts map[int][][]byte
+	batches  []replayBatch
+}
+
+// replayBatch 是一个待发送的批次, offset 为相对回放开始的发送时间
+type replayBatch struct {
+	shardID        int
+	offset         time.Duration
+	payload        []byte
+	msg            types.RequestMsg
+	transactions   []*types.Transaction
+	cstransactions []*types.CrossShardTransaction
+}
+
+// LoadDataset 读取数据集目录中的账户与批次, rate 为 0 时按照记录的 Timestamp 排列发送时间
+func LoadDataset(dir string, rate, speedup float64) (*DatasetReplay, error) {
+	manifest, err := dataset.ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if rate < 0 || speedup <= 0 {
+		return nil, fmt.Errorf("%w: rate must not be negative and speedup must be positive", errInvalidParam)
+	}
+	if manifest.Codec, err = types.ParseCodec(string(manifest.Codec)); err != nil {
+		return nil, err
+	}
+	replay := &DatasetReplay{Dir: dir, Manifest: manifest, Rate: rate, Speedup: speedup, SendAccounts: true, accounts: make(map[int][][]byte)}
+	for shardID := range manifest.Accounts {
+		if replay.accounts[shardID], err = dataset.ReadAll(dataset.AccountsPath(dir, shardID, manifest.Format), manifest.Format); err != nil {
+			return nil, err
+		}
+	}
+	for shardID := range manifest.Batches {
+		records, err := dataset.ReadAll(dataset.BatchesPath(dir, shardID, manifest.Format), manifest.Format)
+		if err != nil {
+			return nil, err
+		}
+		for i, record := range records {
+			batch, err := decodeReplayBatch(shardID, record)
+			if err != nil {
+				return nil, fmt.Errorf("shard %d batch %d: %w", shardID, i, err)
+			}
+			if rate > 0 {
+				batch.offset = time.Duration(float64(i) / rate * float64(time.Second))
+			}
+			replay.batches = append(replay.batches, batch)
+		}
+	}
+	if len(replay.batches) == 0 {
+		return nil, fmt.Errorf("dataset %s has no batches", dir)
+	}
+	if rate == 0 {
+		first := replay.batches[0].msg.Timestamp
+		for _, batch := range replay.batches {
+			first = min(first, batch.msg.Timestamp)
+		}
+		for i := range replay.batches {
+			replay.batches[i].offset = time.Duration(float64(replay.batches[i].msg.Timestamp-first) / speedup)
+		}
+	}
+	sort.SliceStable(replay.batches, func(i, j int) bool {
+		if replay.batches[i].offset != replay.batches[j].offset {
+			return replay.batches[i].offset < replay.batches[j].offset
+		}
+		return replay.batches[i].shardID < replay.batches[j].shardID
+	})
+	return replay, nil
+}
+
+// decodeReplayBatch 解析批次的序号, 时间戳与交易 hash, 用于调度和回执匹配
+func decodeReplayBatch(shardID int, record []byte) (replayBatch, error) {
+	batch := replayBatch{shardID: shardID, payload: record}
+	if err := json.Unmarshal(record, &batch.msg); err != nil {
+		return batch, err
+	}
+	codec, err := types.ParseCodec(string(batch.msg.Codec))
+	if err != nil {
+		return batch, err
+	}
+	for _, content := range batch.msg.Transactions {
+		tx := &types.Transaction{}
+		if err := codec.Decode(content, tx); err != nil {
+			return batch, err
+		}
+		batch.transactions = append(batch.transactions, tx)
+	}
+	for _, content := range batch.msg.CrossShardTransactions {
+		ctx := &types.CrossShardTransaction{}
+		if err := codec.Decode(content, ctx); err != nil {
+			return batch, err
+		}
+		batch.cstransactions = append(batch.cstransactions, ctx)
+	}
+	return batch, nil
+}
+
+// ReplayDataset 以 job 的形式回放数据集, job 结束后 Done 被关闭
+func (s *Server) ReplayDataset(replay *DatasetReplay) (*Job, error) {
+	for _, batch := range replay.batches {
+		if _, err := s.Shards.Leader(batch.shardID); err != nil {
+			return nil, err
+		}
+	}
+	cfg := JobConfig{
+		ShardID:  traceJobShard,
+		Mode:     ModeDataset,
+		Interval: s.Config.GenerationInterval.Duration,
+		Number:   len(replay.batches),
+		Options:  generator.Options{Ledger: s.Ledger},
+		Dataset:  replay,
+	}
+	return s.startJob(cfg, false, nil)
+}
+
+// runDatasetJob 先发送账户, 再按照时间表把批次投递给每个 shard 的发送协程
+// NOTE: 每个 shard 按顺序发送自己的批次, 一个 shard 变慢不会推迟其他 shard 的发送时间
+func (s *Server) runDatasetJob(job *Job) {
+	replay := job.Dataset
+	if replay.SendAccounts {
+		if err := s.sendDatasetAccounts(replay); err != nil {
+			log.Printf("[ERROR] Job %s: %v", job.ID, err)
+			job.recordFailure(err)
+			_ = job.Stop()
+			return
+		}
+	}
+
+	queues := make(map[int]chan replayBatch)
+	for _, batch := range replay.batches {
+		queues[batch.shardID] = nil
+		s.reserveSequenceID(batch.shardID, batch.msg.SequenceID)
+	}
+	var senders sync.WaitGroup
+	for shardID := range queues {
+		queue := make(chan replayBatch, replay.Manifest.Batches[shardID]+1)
+		queues[shardID] = queue
+		senders.Add(1)
+		go func() {
+			defer senders.Done()
+			for batch := range queue {
+				s.applyReplayBatch(job, batch)
+				err := s.submitReplayBatch(batch)
+				if err != nil {
+					log.Printf("[ERROR] Job %s: %v", job.ID, err)
+				}
+				job.recordBatch(batch.msg, err)
+			}
+		}()
+	}
+	// NOTE: 等待所有发送协程结束后再停止 job, 保证 Done 关闭时统计已经完整
+	defer func() {
+		for _, queue := range queues {
+			close(queue)
+		}
+		senders.Wait()
+		s.saveJobShards(job)
+		_ = job.Stop()
+	}()
+
+	// NOTE: 以 job 的运行时间 (不含暂停) 为时钟, 恢复后从暂停的位置继续, 不会补发暂停期间的批次
+	lastSave := time.Now()
+	for _, batch := range replay.batches {
+		for wait := batch.offset - job.active(); wait > 0; wait = batch.offset - job.active() {
+			if !job.wait(wait) {
+				log.Printf("Job %s for dataset %s stopped.", job.ID, replay.Dir)
+				return
+			}
+		}
+		if job.State() == JobStopped {
+			log.Printf("Job %s for dataset %s stopped.", job.ID, replay.Dir)
+			return
+		}
+		queues[batch.shardID] <- batch
+		if time.Since(lastSave) >= saveInterval {
+			s.saveJobShards(job)
+			lastSave = time.Now()
+		}
+	}
+	log.Printf("Job %s finished replaying dataset %s.", job.ID, replay.Dir)
+}
+
+// sendDatasetAccounts 把数据集中每个 shard 的账户发送给 shard, 并作为生成器当前的账户
+func (s *Server) sendDatasetAccounts(replay *DatasetReplay) error {
+	codec := replay.Manifest.Codec
+	for shardID, records := range replay.accounts {
+		accounts := make([]types.Account, len(records))
+		for i, record := range records {
+			if err := codec.Decode(record, &accounts[i]); err != nil {
+				return fmt.Errorf("shard %d account %d: %w", shardID, i, err)
+			}
+		}
+		if err := s.SetAccounts(shardID, accounts); err != nil {
+			log.Printf("[ERROR] Failed to build partitioner: %v", err)
+		}
+		if _, ok := s.Shards.Get(shardID); !ok {
+			continue
+		}
+		jsonData, err := json.Marshal(types.AccountsMsg{Content: records, AddressNumber: len(records), Codec: codec})
+		if err != nil {
+			return err
+		}
+		resp, err := s.postToShard(shardID, "/accounts", jsonData)
+		if err != nil {
+			s.deadLetter(0, jsonData, err)
+			return fmt.Errorf("send accounts to shard %d: %w", shardID, err)
+		}
+		resp.Body.Close()
+		log.Printf("%d accounts sent to shard %d successfully", len(records), shardID)
+	}
+	return nil
+}
+
+// applyReplayBatch 在账本中记录回放批次中的转账, 之后在线生成的交易接着数据集的余额与 nonce 生成
+// NOTE: 账本为每笔转账推进一次 nonce, 与数据集中发送方已签名的交易数一致; 账本拒绝的转账 (例如发送方不在账本中) 计入 job 的拒绝数
+func (s *Server) applyReplayBatch(job *Job, batch replayBatch) {
+	apply := func(from, to string, err error) {
+		if err != nil {
+			reason := generator.RejectionReason(err)
+			s.Metrics.rejections.Inc(shardLabel(batch.shardID), reason)
+			job.recordRejection(reason)
+			return
+		}
+		s.touchAccounts(job, from, to)
+	}
+	for _, tx := range batch.transactions {
+		fee, err := tx.Fee()
+		if err == nil {
+			_, err = s.Ledger.Transfer(tx.From, tx.To, tx.Value, fee)
+		}
+		apply(tx.From, tx.To, err)
+	}
+	for _, ctx := range batch.cstransactions {
+		fee, err := ctx.Fee()
+		if err == nil {
+			_, err = s.Ledger.TransferCrossShard(ctx.From, ctx.To, ctx.Value, fee)
+		}
+		apply(ctx.From, ctx.To, err)
+	}
+}
+
+// submitReplayBatch 发送批次的原始请求体, 并与在线生成的批次一样记录发送时间用于延迟统计
+func (s *Server) submitReplayBatch(batch replayBatch) error {
+	s.Receipts.Prepare(batch.shardID, batch.msg.SequenceID, batch.transactions, batch.cstransactions)
+	s.Receipts.Sent(batch.shardID, batch.msg.SequenceID, time.Now())
+	resp, err := s.postToShard(batch.shardID, "/req", batch.payload)
+	if err != nil {
+		s.deadLetter(batch.msg.SequenceID, batch.payload, err)
+		err = fmt.Errorf("send transactions to shard %d: %w", batch.shardID, err)
+	} else {
+		resp.Body.Close()
+	}
+	s.Receipts.Done(batch.shardID, batch.msg.SequenceID, err)
+	return err
+}
+
+func (s *Server) handleReplayDataset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+	if params.Get("path") == "" {
+		http.Error(w, "Missing path", http.StatusBadRequest)
+		return
+	}
+	dir, err := s.dataPath(params.Get("path"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rate, speedup := 0.0, 1.0
+	for name, value := range map[string]*float64{"rate": &rate, "speedup": &speedup} {
+		if param := params.Get(name); param != "" {
+			var err error
+			if *value, err = strconv.ParseFloat(param, 64); err != nil {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	replay, err := LoadDataset(dir, rate, speedup)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if param := params.Get("accounts"); param != "" {
+		if replay.SendAccounts, err = strconv.ParseBool(param); err != nil {
+			http.Error(w, "Invalid accounts", http.StatusBadRequest)
+			return
+		}
+	}
+	for _, batch := range replay.batches {
+		if _, err := s.Shards.Leader(batch.shardID); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	}
+	job, err := s.ReplayDataset(replay)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	log.Printf("Started job %s replaying %d batches from %s.", job.ID, len(replay.batches), dir)
+	writeJSON(w, job.Status())
+}
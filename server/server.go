@@ -1,44 +1,295 @@
 package server
 
 import (
-	"bytes"
-	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"generator_boilerplate/constant"
+	"generator_boilerplate/config"
 	"generator_boilerplate/generator"
+	"generator_boilerplate/store"
 	"generator_boilerplate/types"
 	"log"
-	"math"
 	"math/big"
+	"net"
 	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
-var SequenceID = 1
+var (
+	ErrInfeasibleBatch = errors.New("batch size is infeasible for the account set")
+	// NOTE: 尝试次数用完时一笔交易都没有生成
+	ErrAttemptsExhausted = errors.New("generation attempts exhausted")
+)
 
 type Server struct {
 	Port string
+	// NOTE: stateMu 保护 AddressMap, Partitioner, view 与 migrated, 它们只会被整体替换 (copy-on-write),
+	// 因此读取方在锁内取得引用后可以在锁外继续使用
+	stateMu sync.RWMutex
 	// NOTE: 用于生成transaction
 	AddressMap map[int][]types.Account
-	// NOTE: 用于记录每个 shard 的 #0 节点
-	ShardsTable map[string]string
+	// NOTE: 用于记录每个 shard 的 #0 节点, 可以通过接口动态注册/注销
+	Shards *Registry
+	// NOTE: 生成参数, 其中 Seed 为 0 表示使用 crypto/rand, Mnemonic 非空时按照 BIP-44 派生账户
+	Config *config.Config
+	// NOTE: 持久化 AddressMap, 重启后从中恢复每个 shard 的账户
+	Store store.Store
+	// NOTE: 影子账本, 记录已生成交易对余额的影响
+	Ledger *generator.Ledger
+	// NOTE: 地址到 shard 的划分策略, 以及按照它重新分组的账户, 生成交易时以 view 为准
+	Partitioner generator.Partitioner
+	view        map[int][]types.Account
+	// NOTE: 迁移过的账户 (小写地址) 当前所在的 shard, 优先于划分策略
+	migrated map[string]int
+	// NOTE: 记录交易的发送时间, 与 shard 回调的回执匹配计算端到端延迟
+	Receipts *ReceiptTracker
+	Metrics  *Metrics
+	// NOTE: 重试后仍然失败的请求
+	DeadLetters *DeadLetters
+
+	client *http.Client
+	mux    *http.ServeMux
+
+	// NOTE: 每个 shard 独立的 RequestMsg/MigrationMsg 序号
+	sequenceMu sync.Mutex
+	sequences  map[int]int64
+
+	jobsMu    sync.Mutex
+	jobs      map[string]*Job
+	nextJobID int
+}
+
+type ShardState struct {
+	ShardID  int `json:"shard_id"`
+	Accounts int `json:"accounts"`
 }
 
-func NewServer(port string) *Server {
+func NewServer(cfg *config.Config, st store.Store) *Server {
 	server := &Server{
-		Port:        port,
+		Port:        cfg.Port,
 		AddressMap:  make(map[int][]types.Account),
-		ShardsTable: make(map[string]string),
+		Config:      cfg,
+		Store:       st,
+		Ledger:      generator.NewLedger(),
+		migrated:    make(map[string]int),
+		Receipts:    NewReceiptTracker(),
+		Metrics:     NewMetrics(),
+		DeadLetters: NewDeadLetters(cfg.StateDir),
+		client:      &http.Client{Timeout: cfg.RequestTimeout.Duration},
+		mux:         http.NewServeMux(),
+		sequences:   make(map[int]int64),
+		jobs:        make(map[string]*Job),
+	}
+	shards, err := NewRegistry(cfg.ShardsTable)
+	if err != nil {
+		log.Printf("[ERROR] Invalid shards table: %v", err)
+		shards = &Registry{shards: make(map[int]ShardInfo)}
+	}
+	server.Shards = shards
+	server.setRoutes()
+	loaded, err := st.Load()
+	if err != nil {
+		// NOTE: 从空状态启动, 划分视图仍然需要构造
+		log.Printf("[ERROR] Failed to load account state: %v", err)
+		loaded = nil
+	}
+	for shardID, accounts := range loaded {
+		server.AddressMap[shardID] = accounts
+		server.Ledger.SetAccounts(shardID, accounts)
+		log.Printf("Loaded %d accounts for shard %d.", len(accounts), shardID)
+	}
+	if err := server.refreshPartition(); err != nil {
+		log.Printf("[ERROR] Failed to build partitioner: %v", err)
+	}
+	// NOTE: 持久化的账户位置与划分策略不一致时, 认为账户已经被迁移过
+	for shardID, accounts := range loaded {
+		for _, acc := range accounts {
+			if placed := server.Partitioner.Shard(acc.Address); placed >= 0 && placed != shardID {
+				server.migrated[strings.ToLower(acc.Address)] = shardID
+			}
+		}
+	}
+	if len(server.migrated) > 0 {
+		log.Printf("Restored placement of %d migrated accounts.", len(server.migrated))
+		server.refreshPartition()
 	}
-	server.ShardsTable = constant.ShardsTable
 	return server
 }
 
+// source 根据请求中的 seed 参数 (缺省为 Config.Seed) 为 shard 派生随机源
+func (s *Server) source(params url.Values, shardID int) (*generator.Source, error) {
+	seed := s.Config.Seed
+	if param := params.Get("seed"); param != "" {
+		var err error
+		seed, err = strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return generator.DeriveSource(seed, shardID), nil
+}
+
+// codec 返回请求中的 codec 参数 (缺省为 Config.Codec) 对应的编码方式
+func (s *Server) codec(params url.Values) (types.Codec, error) {
+	name := s.Config.Codec
+	if param := params.Get("codec"); param != "" {
+		name = param
+	}
+	return types.ParseCodec(name)
+}
+
+// ethereumConfig 根据请求中的 tx_type / chain_id / gas_* 参数 (缺省为配置) 返回以太坊交易的参数, native 格式返回 nil
+func (s *Server) ethereumConfig(params url.Values, codec types.Codec) (*types.EthereumConfig, error) {
+	txType := types.TxType(s.Config.TxType)
+	if param := params.Get("tx_type"); param != "" {
+		var err error
+		if txType, err = types.ParseTxType(param); err != nil {
+			return nil, err
+		}
+	}
+	if txType == types.TxNative {
+		if codec == types.CodecEthereum {
+			return nil, fmt.Errorf("codec %s requires tx_type %s or %s", codec, types.TxLegacy, types.TxDynamic)
+		}
+		return nil, nil
+	}
+	values := map[string]int64{
+		"chain_id":    s.Config.ChainID,
+		"gas_limit":   s.Config.GasLimit,
+		"gas_price":   s.Config.GasPrice,
+		"gas_tip_cap": s.Config.GasTipCap,
+		"gas_fee_cap": s.Config.GasFeeCap,
+	}
+	for name := range values {
+		if param := params.Get(name); param != "" {
+			value, err := strconv.ParseInt(param, 10, 64)
+			if err != nil || value < 0 {
+				return nil, fmt.Errorf("invalid %s %q", name, param)
+			}
+			values[name] = value
+		}
+	}
+	cfg := &types.EthereumConfig{
+		Type:      txType,
+		ChainID:   big.NewInt(values["chain_id"]),
+		GasLimit:  uint64(values["gas_limit"]),
+		GasPrice:  big.NewInt(values["gas_price"]),
+		GasTipCap: big.NewInt(values["gas_tip_cap"]),
+		GasFeeCap: big.NewInt(values["gas_fee_cap"]),
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	// NOTE: 账户的初始余额至少要够支付一笔转账的最大 gas 费用与金额 1, 否则账本会拒绝所有交易
+	if cfg.MaxFee().Cmp(big.NewInt(s.Config.Balance-1)) > 0 {
+		return nil, fmt.Errorf("balance %d cannot pay the max gas cost %s of a transfer", s.Config.Balance, cfg.MaxFee())
+	}
+	return cfg, nil
+}
+
+// dataPath 将请求参数中的文件名解析为 Config.DataDir 下的路径, 拒绝绝对路径与包含 .. 的路径
+func (s *Server) dataPath(name string) (string, error) {
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("path %q must be relative to the data directory", name)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if part == ".." {
+			return "", fmt.Errorf("path %q must not contain ..", name)
+		}
+	}
+	return filepath.Join(s.Config.DataDir, name), nil
+}
+
+// mnemonic 返回 params 中的 mnemonic, 缺省为 Config.Mnemonic
+// NOTE: 助记词只能放在 POST 请求体中, 由 handleGenerateAccounts 合并到 params
+func (s *Server) mnemonic(params url.Values) string {
+	if mnemonic := params.Get("mnemonic"); mnemonic != "" {
+		return mnemonic
+	}
+	return s.Config.Mnemonic
+}
+
 func (s *Server) setRoutes() {
-	http.HandleFunc("/generate_account", s.handleGenerateAccounts)
-	http.HandleFunc("/generate_transaction", s.handleGenerateTransactions)
+	s.mux.HandleFunc("/generate_account", s.handleGenerateAccounts)
+	s.mux.HandleFunc("/generate_transaction", s.handleGenerateTransactions)
+	s.mux.HandleFunc("/state", s.handleState)
+	s.mux.HandleFunc("/balances", s.handleBalances)
+	s.mux.HandleFunc("/sync_nonces", s.handleSyncNonces)
+	s.mux.HandleFunc("/partition", s.handlePartition)
+	s.mux.HandleFunc("/shards", s.handleListShards)
+	s.mux.HandleFunc("/register_shard", s.handleRegisterShard)
+	s.mux.HandleFunc("/deregister_shard", s.handleDeregisterShard)
+	s.mux.HandleFunc("/replay_trace", s.handleReplayTrace)
+	s.mux.HandleFunc("/replay_dataset", s.handleReplayDataset)
+	s.mux.HandleFunc("/migrate_accounts", s.handleMigrateAccounts)
+	s.mux.HandleFunc("/receipts", s.handleReceipts)
+	s.mux.HandleFunc("/latency", s.handleLatency)
+	s.mux.Handle("/metrics", s.Metrics.Registry)
+	s.mux.HandleFunc("/dead_letters", s.handleDeadLetters)
+	s.mux.HandleFunc("/jobs", s.handleListJobs)
+	s.mux.HandleFunc("/jobs/status", s.handleJobStatus)
+	s.mux.HandleFunc("/jobs/pause", s.handleJobAction(pauseJob))
+	s.mux.HandleFunc("/jobs/resume", s.handleJobAction(resumeJob))
+	s.mux.HandleFunc("/jobs/rate", s.handleJobAction(setJobRate))
+	s.mux.HandleFunc("/jobs/stop", s.handleJobAction(stopJob))
+}
+
+// handleState 列出已加载账户状态的 shard
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	addressMap := s.addressMap()
+	states := make([]ShardState, 0, len(addressMap))
+	for shardID, accounts := range addressMap {
+		states = append(states, ShardState{ShardID: shardID, Accounts: len(accounts)})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].ShardID < states[j].ShardID })
+	writeJSON(w, states)
+}
+
+// handleBalances 返回影子账本中 shard 每个账户的余额, 待入账金额和预期最终余额
+func (s *Server) handleBalances(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	shardID, err := strconv.Atoi(r.URL.Query().Get("shard_id"))
+	if err != nil {
+		http.Error(w, "Invalid shard_id", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, s.Ledger.Entries(shardID))
+}
+
+// saveShard 将 shard 的账户连同账本中的最新余额持久化
+func (s *Server) saveShard(shardID int) {
+	if err := s.Store.Save(shardID, s.Ledger.Snapshot(s.addressMap()[shardID])); err != nil {
+		log.Printf("[ERROR] Failed to save accounts of shard %d: %v", shardID, err)
+	}
+}
+
+// touchAccounts 将账户在账本中所属的 shard 记为 job 修改过的 shard
+func (s *Server) touchAccounts(job *Job, addresses ...string) {
+	for _, address := range addresses {
+		if shardID, ok := s.Ledger.ShardOf(address); ok {
+			job.markDirty(shardID)
+		}
+	}
+}
+
+// saveJobShards 持久化 job 修改过的所有 shard, 跨片交易目标 shard 上的待入账金额随之保存
+func (s *Server) saveJobShards(job *Job) {
+	for _, shardID := range job.takeDirty() {
+		s.saveShard(shardID)
+	}
 }
 
 func (s *Server) handleGenerateAccounts(w http.ResponseWriter, r *http.Request) {
@@ -48,43 +299,81 @@ func (s *Server) handleGenerateAccounts(w http.ResponseWriter, r *http.Request)
 	}
 
 	params := r.URL.Query()
+	// NOTE: 助记词不能出现在 URL 中, 否则会被访问日志与代理记录
+	if params.Has("mnemonic") {
+		http.Error(w, "mnemonic must be sent in the request body", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if mnemonic := r.PostForm.Get("mnemonic"); mnemonic != "" {
+		params.Set("mnemonic", mnemonic)
+	}
 	// NOTE: 知道是哪个 shard 需要生成 多少个 accounts
 	param1 := params.Get("shard_id")
 	param2 := params.Get("acc_number")
 
 	shardID, _ := strconv.Atoi(param1)
 	accNumber, _ := strconv.Atoi(param2)
+	// NOTE: 在替换并持久化账户之前检查 shard 是否已注册
+	if _, err := s.Shards.Leader(shardID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	codec, err := s.codec(params)
+	if err != nil {
+		http.Error(w, "Invalid codec", http.StatusBadRequest)
+		return
+	}
 
-	accounts, err := generator.GenerateAccounts(accNumber)
+	accounts, err := s.GenerateAccounts(shardID, accNumber, params)
+	if errors.Is(err, errInvalidParam) {
+		http.Error(w, "Invalid seed", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, generator.ErrInvalidMnemonic) || errors.Is(err, generator.ErrPartitionUnreachable) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Error generating accounts", http.StatusInternalServerError)
 		return
 	}
-	s.AddressMap[shardID] = accounts
+	if err := s.SetAccounts(shardID, accounts); err != nil {
+		log.Printf("[ERROR] Failed to build partitioner: %v", err)
+	}
+	s.Metrics.accounts.Add(float64(len(accounts)), shardLabel(shardID))
 	log.Println("Generated Accounts.")
+	s.saveShard(shardID)
 
-	msg := types.AccountsMsg{}
+	msg := types.AccountsMsg{Codec: codec}
 	msg.Content = make([][]byte, len(accounts))
 	for i := 0; i < len(accounts); i++ {
-		msg.Content[i], _ = accounts[i].Marshal()
+		if msg.Content[i], err = codec.Encode(&accounts[i]); err != nil {
+			log.Printf("[ERROR] Failed to encode account %s: %v", accounts[i].Address, err)
+			http.Error(w, "Error encoding accounts", http.StatusInternalServerError)
+			return
+		}
 	}
 	msg.AddressNumber = accNumber
 	jsonData, err := json.Marshal(msg)
 	if err != nil {
-		log.Fatalf("Failed to JSON marshal account: %v", err)
+		log.Printf("[ERROR] Failed to JSON marshal accounts: %v", err)
+		http.Error(w, "Error encoding accounts", http.StatusInternalServerError)
+		return
 	}
-	fmt.Println(string(jsonData))
-	resp, err := http.Post(s.ShardsTable[fmt.Sprintf("Shard_%d", shardID)]+"/accounts", "application/json", bytes.NewBuffer(jsonData))
+	resp, err := s.postToShard(shardID, "/accounts", jsonData)
 	if err != nil {
-		log.Fatalf("Failed to send account to shard: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Failed to send account to shard %d, received status code: %d", shardID, resp.StatusCode)
-	} else {
-		fmt.Printf("%d accounts sent to shard %d successfully\n", accNumber, shardID)
+		// NOTE: 账户已经生成并保存, shard 恢复后可以根据死信重新发送
+		s.deadLetter(0, jsonData, err)
+		log.Printf("[ERROR] Failed to send accounts to shard %d: %v", shardID, err)
+		http.Error(w, fmt.Sprintf("Failed to send accounts to shard %d: %v", shardID, err), http.StatusBadGateway)
+		return
 	}
+	resp.Body.Close()
+	log.Printf("%d accounts sent to shard %d successfully", accNumber, shardID)
 }
 
 func (s *Server) handleGenerateTransactions(w http.ResponseWriter, r *http.Request) {
@@ -94,98 +383,241 @@ func (s *Server) handleGenerateTransactions(w http.ResponseWriter, r *http.Reque
 	}
 	params := r.URL.Query()
 	param1 := params.Get("shard_id")
-	param2 := params.Get("is_overload")
 	shardID, _ := strconv.Atoi(param1)
-	isOverload, _ := strconv.ParseBool(param2)
-
-	ticker := time.NewTicker(10 * time.Second)
-	go func() {
-		for range ticker.C {
-			// NOTE: 默认生成 1000 笔交易
-			number := constant.TransactionsGeneration
-			if isOverload == true {
-				number = int(math.Round(float64(number) * (1 + constant.OverloadTransactionsRatio)))
-			}
-			log.Println("========== Generating Transactions ==========")
-			generatedTransactions := make([]interface{}, 0)
-			// NOTE: 增加一个计数器，保证交易的分散性
-			counter := make(map[string]int)
-			// NOTE: 控制交易重复
-			repetitive := make(map[string][]string)
-			// NOTE: 控制 nonce
-			noncer := make(map[string]int64)
-			for _, acc := range s.AddressMap[shardID] {
-				counter[acc.Address] = 0
-				repetitive[acc.Address] = make([]string, 0)
-				// NOTE: nonce 的同步也是一个问题
-				noncer[acc.Address] = acc.Nonce + 1
-			}
-			trans, ctrans := 0, 0
-			for trans+ctrans < number {
-				rnd, _ := rand.Int(rand.Reader, big.NewInt(100))
-				if int(rnd.Int64()) > constant.CrossShardTransactionRatio {
-					tx, err := generator.GenerateTransaction(s.AddressMap[shardID], &counter, &repetitive, &noncer)
-					if err != nil {
-						log.Println("[ERROR] Wrong when generating the transactions: ", err)
-						continue
-					}
-					generatedTransactions = append(generatedTransactions, tx)
-					trans += 1
-				} else {
-					ctx, err := generator.GenerateCrossShardTransaction(shardID, s.AddressMap, &counter, &repetitive, &noncer)
-					if err != nil {
-						log.Println("[ERROR] Wrong when generating the cross shard transactions: ", err)
-						continue
-					}
-					generatedTransactions = append(generatedTransactions, ctx)
-					ctrans += 1
-				}
-			}
-			log.Println("========== Generated Transactions ==========")
-
-			msg := types.RequestMsg{}
-			msg.Timestamp = time.Now().UnixNano()
-			msg.Transactions = make([][]byte, 0)
-			msg.CrossShardTransactions = make([][]byte, 0)
-			for i := 0; i < len(generatedTransactions); i++ {
-				switch generatedTransactions[i].(type) {
-				case *types.Transaction:
-					transaction, _ := generatedTransactions[i].(*types.Transaction).Marshal()
-					msg.Transactions = append(msg.Transactions, transaction)
-				case *types.CrossShardTransaction:
-					cstransaction, _ := generatedTransactions[i].(*types.CrossShardTransaction).Marshal()
-					msg.CrossShardTransactions = append(msg.CrossShardTransactions, cstransaction)
-				}
-			}
-			msg.SequenceID = int64(SequenceID)
-			SequenceID++
-			msg.TransactionNumber = len(generatedTransactions)
-			jsonData, err := json.Marshal(msg)
-			fmt.Println(string(jsonData))
+	if _, err := s.Shards.Leader(shardID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	src, err := s.source(params, shardID)
+	if err != nil {
+		http.Error(w, "Invalid seed", http.StatusBadRequest)
+		return
+	}
+	view, _ := s.partitionView()
+	if len(view[shardID]) == 0 {
+		http.Error(w, fmt.Sprintf("No accounts for shard %d", shardID), http.StatusConflict)
+		return
+	}
+	cfg, err := s.jobConfig(shardID, params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// NOTE: 启动时就能确定批次无法生成时直接拒绝, 运行中账户或拓扑的变化由每个批次自己检查
+	if capacity := s.batchCapacity(view, shardID, cfg.Options.MaxTxsInBlock, cfg.CrossShardRatio); cfg.Number > capacity && (cfg.Infeasible == generator.BatchError || capacity == 0) {
+		http.Error(w, fmt.Sprintf("%v: %d transactions requested, shard %d allows at most %d", ErrInfeasibleBatch, cfg.Number, shardID, capacity), http.StatusConflict)
+		return
+	}
+	allowMultiple, _ := strconv.ParseBool(params.Get("allow_multiple"))
+
+	job, err := s.startJob(cfg, allowMultiple, src)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	log.Printf("Started job %s for shard %d.", job.ID, shardID)
+	writeJSON(w, job.Status())
+}
+
+// generateBatch 为 job 对应的 shard 生成一批交易
+// NOTE: 最多尝试 number * AttemptsPerTx 次, 每次尝试只做固定次数的账户选择, 被拒绝的选择同样计入预算;
+// 用完后发送已经生成的部分交易 (它们已经记入账本, 不能丢弃), 一笔都没有生成时返回 ErrAttemptsExhausted;
+// 批次大小超出账户能力时按照 job 的 Infeasible 策略缩小批次或者直接返回 ErrInfeasibleBatch
+func (s *Server) generateBatch(job *Job) (types.RequestMsg, error) {
+	shardID, src, number := job.ShardID, job.src, job.Number()
+	// NOTE: 每个批次都重新读取拓扑与账户视图, 注册/注销 shard 与账户迁移对正在运行的 job 立即生效,
+	// 同一批次内使用同一份视图
+	view, _ := s.partitionView()
+	targets := s.crossShardTargets(view, shardID)
+	capacity := s.batchCapacity(view, shardID, job.Options.MaxTxsInBlock, job.CrossShardRatio)
+	if number > capacity {
+		if job.Infeasible == generator.BatchError || capacity == 0 {
+			return types.RequestMsg{}, fmt.Errorf("%w: %d transactions requested, shard %d allows at most %d", ErrInfeasibleBatch, number, shardID, capacity)
+		}
+		number = capacity
+	}
+	log.Println("========== Generating Transactions ==========")
+	generatedTransactions := make([]interface{}, 0)
+	// NOTE: 增加一个计数器，保证交易的分散性
+	counter := make(map[string]int)
+	// NOTE: 控制交易重复
+	repetitive := make(map[string][]string)
+	// NOTE: nonce 由账本跨批次分配, 不再在每个批次内重置
+	for _, acc := range view[shardID] {
+		counter[acc.Address] = 0
+		repetitive[acc.Address] = make([]string, 0)
+	}
+	reject := func(err error) {
+		reason := generator.RejectionReason(err)
+		s.Metrics.rejections.Inc(shardLabel(shardID), reason)
+		job.recordRejection(reason)
+	}
+	budget := number * job.AttemptsPerTx
+	trans, ctrans, attempts := 0, 0, 0
+	for ; trans+ctrans < number && attempts < budget; attempts++ {
+		rnd, err := src.Intn(100)
+		if err != nil {
+			log.Println("[ERROR] Wrong when drawing random number: ", err)
+			continue
+		}
+		// NOTE: rnd 取值 [0, 100), ratio 为 0 时只生成片内交易, 为 100 时只生成跨片交易
+		if rnd >= job.CrossShardRatio || len(targets) == 0 {
+			tx, err := generator.GenerateTransaction(src, job.Options, view[shardID], &counter, &repetitive)
 			if err != nil {
-				log.Fatalf("Failed to JSON marshal account: %v", err)
+				reject(err)
+				continue
 			}
-
-			resp, err := http.Post(s.ShardsTable[fmt.Sprintf("Shard_%d", shardID)]+"/req", "application/json", bytes.NewBuffer(jsonData))
+			s.touchAccounts(job, tx.From, tx.To)
+			generatedTransactions = append(generatedTransactions, tx)
+			trans += 1
+		} else {
+			ctx, err := generator.GenerateCrossShardTransaction(src, job.Options, shardID, targets, view, &counter, &repetitive)
 			if err != nil {
-				log.Fatalf("Failed to send transactions to shard: %v", err)
+				reject(err)
+				continue
 			}
-			defer resp.Body.Close()
+			s.touchAccounts(job, ctx.From, ctx.To)
+			generatedTransactions = append(generatedTransactions, ctx)
+			ctrans += 1
+		}
+	}
+	log.Println("========== Generated Transactions ==========")
+	if len(generatedTransactions) == 0 {
+		return types.RequestMsg{}, fmt.Errorf("%w: no transaction generated for shard %d in %d attempts", ErrAttemptsExhausted, shardID, attempts)
+	}
+	if len(generatedTransactions) < job.Number() {
+		log.Printf("Generated %d of %d transactions for shard %d in %d attempts.", len(generatedTransactions), job.Number(), shardID, attempts)
+		job.recordPartial()
+	}
 
-			if resp.StatusCode != http.StatusOK {
-				log.Printf("Failed to send transactions to shard %d, received status code: %d", shardID, resp.StatusCode)
-			} else {
-				fmt.Printf("%d transactions sent to shard %d successfully\n", len(generatedTransactions), shardID)
-			}
+	return s.newRequestMsg(shardID, generatedTransactions, src.Deterministic(), job.Codec), nil
+}
+
+// batchCapacity 返回 view 中 shard 的账户在一个批次内最多能发出的交易数
+func (s *Server) batchCapacity(view map[int][]types.Account, shardID, maxTxs, crossShardRatio int) int {
+	crossShard := crossShardRatio > 0 && len(s.crossShardTargets(view, shardID)) > 0
+	return generator.BatchCapacity(len(view[shardID]), maxTxs, crossShard)
+}
+
+// newRequestMsg 将发往 shardID 的交易按照 codec 编码, 打包为 RequestMsg 并分配序号
+func (s *Server) newRequestMsg(shardID int, generatedTransactions []interface{}, deterministic bool, codec types.Codec) types.RequestMsg {
+	msg := types.RequestMsg{Codec: codec}
+	msg.SequenceID = s.nextSequenceID(shardID)
+	msg.Timestamp = time.Now().UnixNano()
+	if deterministic {
+		// NOTE: 确定性模式下使用逻辑时间戳, 保证相同 seed 生成的 RequestMsg 字节一致
+		msg.Timestamp = msg.SequenceID * int64(s.Config.GenerationInterval.Duration)
+	}
+	transactions := make([]*types.Transaction, 0, len(generatedTransactions))
+	cstransactions := make([]*types.CrossShardTransaction, 0)
+	for i := 0; i < len(generatedTransactions); i++ {
+		switch tx := generatedTransactions[i].(type) {
+		case *types.Transaction:
+			transactions = append(transactions, tx)
+		case *types.CrossShardTransaction:
+			cstransactions = append(cstransactions, tx)
+		}
+	}
+	// NOTE: 对整个批次建 Merkle 树, 跨片交易携带自己的包含证明, 供目标 shard 验证
+	leaves := make([][]byte, 0, len(generatedTransactions))
+	for _, tx := range transactions {
+		leaves = append(leaves, tx.Hash)
+	}
+	for _, ctx := range cstransactions {
+		leaves = append(leaves, ctx.Hash)
+	}
+	tree := types.NewMerkleTree(leaves)
+	msg.Root = tree.Root()
+
+	msg.Transactions = make([][]byte, 0)
+	msg.CrossShardTransactions = make([][]byte, 0)
+	for _, tx := range transactions {
+		transaction, err := codec.Encode(tx)
+		if err != nil {
+			log.Printf("[ERROR] Failed to encode transaction %x: %v", tx.Hash, err)
+		}
+		msg.Transactions = append(msg.Transactions, transaction)
+	}
+	for i, ctx := range cstransactions {
+		if err := ctx.SetProof(tree.Proof(len(transactions) + i)); err != nil {
+			log.Println("[ERROR] Wrong when encoding the merkle proof: ", err)
+		}
+		cstransaction, err := codec.Encode(ctx)
+		if err != nil {
+			log.Printf("[ERROR] Failed to encode cross shard transaction %x: %v", ctx.Hash, err)
+		}
+		msg.CrossShardTransactions = append(msg.CrossShardTransactions, cstransaction)
+	}
+	msg.TransactionNumber = len(generatedTransactions)
+	s.Receipts.Prepare(shardID, msg.SequenceID, transactions, cstransactions)
+	s.Metrics.transactions.Add(float64(len(transactions)), shardLabel(shardID), TxIntra)
+	s.Metrics.transactions.Add(float64(len(cstransactions)), shardLabel(shardID), TxCrossShard)
+	s.Metrics.batchSize.Observe(float64(msg.TransactionNumber), shardLabel(shardID))
+	return msg
+}
+
+// crossShardTargets 返回已注册且在 view 中拥有账户的其他 shard, 作为跨片交易的目标
+func (s *Server) crossShardTargets(view map[int][]types.Account, shardID int) []int {
+	targets := make([]int, 0)
+	for _, id := range s.Shards.IDs() {
+		if id != shardID && len(view[id]) > 0 {
+			targets = append(targets, id)
 		}
-	}()
+	}
+	return targets
+}
+
+// submitBatch 将一批交易发送给 shard 的 #0 节点, 并记录发送时间与结果用于延迟统计
+func (s *Server) submitBatch(shardID int, msg types.RequestMsg) error {
+	s.Receipts.Sent(shardID, msg.SequenceID, time.Now())
+	err := s.postBatch(shardID, msg)
+	s.Receipts.Done(shardID, msg.SequenceID, err)
+	return err
 }
 
-func (s *Server) Start() {
-	s.setRoutes()
-	fmt.Printf("Server is running on http://0.0.0.0:%s/\n", s.Port)
-	err := http.ListenAndServe("0.0.0.0:"+s.Port, nil)
+func (s *Server) postBatch(shardID int, msg types.RequestMsg) error {
+	jsonData, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	resp, err := s.postToShard(shardID, "/req", jsonData)
 	if err != nil {
-		log.Fatal(err)
+		s.deadLetter(msg.SequenceID, jsonData, err)
+		return fmt.Errorf("send transactions to shard %d: %w", shardID, err)
 	}
+	resp.Body.Close()
+	log.Printf("%d transactions sent to shard %d successfully", msg.TransactionNumber, shardID)
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[ERROR] Failed to write response: %v", err)
+	}
+}
+
+// Handler 返回注册了所有接口的 http.Handler
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Start 在 Port 上监听并处理请求, 直到监听失败
+func (s *Server) Start() error {
+	l, err := s.Listen()
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Listen 同步地在 Port 上监听, 端口被占用时立即返回错误
+func (s *Server) Listen() (net.Listener, error) {
+	return net.Listen("tcp", "0.0.0.0:"+s.Port)
+}
+
+// Serve 在 l 上处理请求, 直到 l 被关闭
+func (s *Server) Serve(l net.Listener) error {
+	log.Printf("Server is running on http://%s/", l.Addr())
+	return http.Serve(l, s.mux)
 }
@@ -0,0 +1,618 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"generator_boilerplate/config"
+	"generator_boilerplate/dataset"
+	"generator_boilerplate/generator"
+	"generator_boilerplate/store"
+	"generator_boilerplate/types"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGenerateBatchBudget(t *testing.T) {
+	cfg := config.Default()
+	cfg.ShardsTable = map[string]string{"Shard_0": "http://127.0.0.1:1"}
+	s := NewServer(cfg, store.NewMemoryStore())
+	accounts, err := generator.GenerateAccounts(generator.NewSource(1), 3, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Ledger.SetAccounts(0, accounts)
+	s.setShardAccounts(0, accounts)
+
+	newBatchJob := func(params url.Values) *Job {
+		jobCfg, err := s.jobConfig(0, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return newJob("job", jobCfg, generator.DeriveSource(1, 0))
+	}
+
+	// NOTE: 3 个账户且没有其他 shard 时, 每个发送方只有 2 个不重复的接收方
+	job := newBatchJob(url.Values{"number": {"10"}})
+	msg, err := s.generateBatch(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := job.Status()
+	if msg.TransactionNumber == 0 || msg.TransactionNumber > 6 || status.PartialBatches != 1 {
+		t.Fatalf("expected a partial batch of at most 6 transactions, have %d (%+v)", msg.TransactionNumber, status)
+	}
+	if status.Rejections["repetitive"] == 0 {
+		t.Fatalf("expected repetitive rejections, have %v", status.Rejections)
+	}
+
+	job = newBatchJob(url.Values{"number": {"10"}, "infeasible": {"error"}})
+	if _, err := s.generateBatch(job); !errors.Is(err, ErrInfeasibleBatch) {
+		t.Fatalf("expected ErrInfeasibleBatch, have %v", err)
+	}
+
+	// NOTE: selector 总是选择下标 0 时, 第一笔之后的尝试全部因为重复被拒绝, 直到用完预算
+	job = newBatchJob(url.Values{"number": {"2"}, "dist": {"hotspot"}, "hot_tx": {"1"}, "hot_acc": {"0.01"}})
+	msg, err = s.generateBatch(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := job.Status(); msg.TransactionNumber != 1 || status.Rejections["repetitive"] != 2*job.AttemptsPerTx-1 {
+		t.Fatalf("expected 1 transaction and %d repetitive rejections, have %d (%v)", 2*job.AttemptsPerTx-1, msg.TransactionNumber, status.Rejections)
+	}
+
+	broke := make([]types.Account, len(accounts))
+	for i, acc := range accounts {
+		acc.Balance = 0
+		broke[i] = acc
+	}
+	s.Ledger.SetAccounts(0, broke)
+	job = newBatchJob(url.Values{"number": {"2"}})
+	if _, err := s.generateBatch(job); !errors.Is(err, ErrAttemptsExhausted) {
+		t.Fatalf("expected ErrAttemptsExhausted, have %v", err)
+	}
+	if rejected := job.Status().Rejections["insufficient_balance"]; rejected != 2*job.AttemptsPerTx {
+		t.Fatalf("expected the whole budget to be rejected, have %d", rejected)
+	}
+
+	s.setShardAccounts(0, accounts[:1])
+	job = newBatchJob(url.Values{"number": {"1"}})
+	if _, err := s.generateBatch(job); !errors.Is(err, ErrInfeasibleBatch) {
+		t.Fatalf("a single account cannot send intra-shard transactions, have %v", err)
+	}
+}
+
+func TestCrossShardRatio(t *testing.T) {
+	cfg := config.Default()
+	cfg.ShardsTable = map[string]string{"Shard_0": "", "Shard_1": ""}
+	s := NewServer(cfg, store.NewMemoryStore())
+	for shardID := 0; shardID < 2; shardID++ {
+		accounts, err := s.GenerateAccounts(shardID, 10, url.Values{"seed": {"4"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.SetAccounts(shardID, accounts)
+	}
+	for ratio, wantCrossShard := range map[string]bool{"0": false, "100": true} {
+		jobCfg, err := s.jobConfig(0, url.Values{"number": {"9"}, "cross_shard_ratio": {ratio}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		job := newJob("job", jobCfg, generator.DeriveSource(4, 0))
+		job.ticker.Stop()
+		for batch := 0; batch < 20; batch++ {
+			msg, err := s.generateBatch(job)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if crossShard := len(msg.CrossShardTransactions); (crossShard > 0) != wantCrossShard || (wantCrossShard && len(msg.Transactions) > 0) {
+				t.Fatalf("cross_shard_ratio %s: batch %d has %d intra-shard and %d cross-shard transactions", ratio, batch, len(msg.Transactions), crossShard)
+			}
+		}
+	}
+}
+
+// shardStub 模拟 shard 的 #0 节点, 记录收到的批次序号
+type shardStub struct {
+	mu         sync.Mutex
+	sequences  []int64
+	requests   []types.RequestMsg
+	migrations []types.MigrationMsg
+}
+
+func (st *shardStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/migrate" {
+		var msg types.MigrationMsg
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		st.migrations = append(st.migrations, msg)
+		return
+	}
+	if r.URL.Path != "/req" {
+		return
+	}
+	var msg types.RequestMsg
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.sequences = append(st.sequences, msg.SequenceID)
+	st.requests = append(st.requests, msg)
+}
+
+func (st *shardStub) received() []int64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return append([]int64(nil), st.sequences...)
+}
+
+func (st *shardStub) receivedMigrations() []types.MigrationMsg {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return append([]types.MigrationMsg(nil), st.migrations...)
+}
+
+func (st *shardStub) receivedRequests() []types.RequestMsg {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return append([]types.RequestMsg(nil), st.requests...)
+}
+
+// newTestServer 启动一个连接 shards 个 shardStub 的生成器, 返回生成器的 httptest 地址
+func newTestServer(t *testing.T, shards int) (*Server, string, []*shardStub) {
+	t.Helper()
+	stubs := make([]*shardStub, shards)
+	cfg := config.Default()
+	cfg.ShardsTable = make(map[string]string)
+	for i := range stubs {
+		stubs[i] = &shardStub{}
+		ts := httptest.NewServer(stubs[i])
+		t.Cleanup(ts.Close)
+		cfg.ShardsTable[fmt.Sprintf("Shard_%d", i)] = ts.URL
+	}
+	s := NewServer(cfg, store.NewMemoryStore())
+	generatorServer := httptest.NewServer(s.Handler())
+	t.Cleanup(generatorServer.Close)
+	t.Cleanup(func() {
+		s.jobsMu.Lock()
+		defer s.jobsMu.Unlock()
+		for _, job := range s.jobs {
+			_ = job.Stop()
+		}
+	})
+	return s, generatorServer.URL, stubs
+}
+
+// call 发送请求并返回状态码, 状态码为 200 且 v 非 nil 时将响应解码到 v
+func call(t *testing.T, method, url string, v interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK && v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// NOTE: 使用 go test -race 运行, 检查账户生成与多个 shard 的 job 并发时没有数据竞争
+func TestConcurrentShardJobs(t *testing.T) {
+	const shards = 3
+	s, base, stubs := newTestServer(t, shards)
+
+	post := func(path string) (*http.Response, error) {
+		return http.Post(base+path, "application/json", nil)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < shards-1; i++ {
+		wg.Add(1)
+		go func(shardID int) {
+			defer wg.Done()
+			resp, err := post(fmt.Sprintf("/generate_account?shard_id=%d&acc_number=10&seed=%d", shardID, shardID+1))
+			if err != nil {
+				errs <- err
+				return
+			}
+			resp.Body.Close()
+			resp, err = post(fmt.Sprintf("/generate_transaction?shard_id=%d&interval=5ms&number=5", shardID))
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				errs <- fmt.Errorf("starting job for shard %d: status code %d", shardID, resp.StatusCode)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// NOTE: job 运行期间为最后一个 shard 生成账户, 同时读取状态
+	deadline := time.Now().Add(100 * time.Millisecond)
+	for round := 0; time.Now().Before(deadline); round++ {
+		wg.Add(2)
+		go func(round int) {
+			defer wg.Done()
+			resp, err := post(fmt.Sprintf("/generate_account?shard_id=%d&acc_number=5&seed=%d", shards-1, round+100))
+			if err != nil {
+				errs <- err
+				return
+			}
+			resp.Body.Close()
+		}(round)
+		go func() {
+			defer wg.Done()
+			for _, path := range []string{"/state", "/partition", "/jobs"} {
+				resp, err := http.Get(base + path)
+				if err != nil {
+					errs <- err
+					return
+				}
+				resp.Body.Close()
+			}
+		}()
+		time.Sleep(10 * time.Millisecond)
+	}
+	wg.Wait()
+
+	s.jobsMu.Lock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	s.jobsMu.Unlock()
+	for _, job := range jobs {
+		if err := job.Stop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if len(jobs) != shards-1 {
+		t.Fatalf("expected %d jobs, have %d", shards-1, len(jobs))
+	}
+
+	// NOTE: 每个 shard 的序号各自从 1 开始连续递增
+	for i := 0; i < shards-1; i++ {
+		received := stubs[i].received()
+		if len(received) == 0 {
+			t.Fatalf("shard %d received no batches", i)
+		}
+		for j, sequenceID := range received {
+			if sequenceID != int64(j+1) {
+				t.Fatalf("shard %d received sequence IDs %v", i, received)
+			}
+		}
+	}
+}
+
+func TestBatchGenerator(t *testing.T) {
+	cfg := config.Default()
+	cfg.ShardsTable = map[string]string{"Shard_0": "", "Shard_1": ""}
+	s := NewServer(cfg, store.NewMemoryStore())
+	for shardID := 0; shardID < 2; shardID++ {
+		accounts, err := s.GenerateAccounts(shardID, 5, url.Values{"seed": {"3"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.SetAccounts(shardID, accounts)
+	}
+	g, err := s.NewBatchGenerator(0, url.Values{"number": {"4"}, "interval": {"2s"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last types.RequestMsg
+	for i := 0; i < 3; i++ {
+		msg, err := g.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.SequenceID != int64(i+1) || msg.TransactionNumber != 4 {
+			t.Fatalf("unexpected batch %d: sequence %d with %d transactions", i, msg.SequenceID, msg.TransactionNumber)
+		}
+		if i > 0 && time.Duration(msg.Timestamp-last.Timestamp) != 2*time.Second {
+			t.Fatalf("batches should be %v apart, have %v", 2*time.Second, time.Duration(msg.Timestamp-last.Timestamp))
+		}
+		last = msg
+	}
+	// NOTE: 离线生成的批次不会等待回执
+	if report := s.Receipts.Report(); len(report.Groups) != 0 {
+		t.Fatalf("offline batches should not be tracked, have %+v", report)
+	}
+}
+
+func TestReplayDataset(t *testing.T) {
+	stub := &shardStub{}
+	shard := httptest.NewServer(stub)
+	defer shard.Close()
+	cfg := config.Default()
+	cfg.ShardsTable = map[string]string{"Shard_0": shard.URL}
+	s := NewServer(cfg, store.NewMemoryStore())
+	accounts, err := s.GenerateAccounts(0, 5, url.Values{"seed": {"3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetAccounts(0, accounts)
+
+	dir := t.TempDir()
+	w, err := dataset.Create(dataset.BatchesPath(dir, 0, dataset.Binary), dataset.Binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NOTE: 批次中的交易使用 rlp 编码, 回放时根据 RequestMsg 的 codec 解码
+	g, err := s.NewBatchGenerator(0, url.Values{"number": {"2"}, "interval": {"1s"}, "seed": {"3"}, "codec": {"rlp"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		msg, err := g.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		record, _ := json.Marshal(msg)
+		if err := w.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+	if err := dataset.WriteManifest(dir, dataset.Manifest{Format: dataset.Binary, Shards: []int{0}, Batches: map[int]int{0: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	// NOTE: 生成数据集时推进过的账本恢复为初始状态, 回放之后应当重新回到生成后的状态
+	generated := s.Ledger.Entries(0)
+	s.SetAccounts(0, accounts)
+
+	// NOTE: 按照记录的时间戳发送时, 批次间隔被 speedup 压缩
+	replay, err := LoadDataset(dir, 0, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, batch := range replay.batches {
+		if len(batch.transactions)+len(batch.cstransactions) != 2 || batch.transactions[0].From == "" {
+			t.Fatalf("batch %d decoded as %+v", i, batch.transactions)
+		}
+		if want := time.Duration(i) * 250 * time.Millisecond; batch.offset != want {
+			t.Fatalf("batch %d scheduled at %v, want %v", i, batch.offset, want)
+		}
+	}
+
+	if replay, err = LoadDataset(dir, 100, 1); err != nil {
+		t.Fatal(err)
+	}
+	job, err := s.ReplayDataset(replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-job.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("replay did not finish")
+	}
+	if status := job.Status(); status.BatchesSent != 3 || status.TxsSent != 6 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if received := stub.received(); len(received) != 3 || received[0] != 1 || received[2] != 3 {
+		t.Fatalf("shard received sequence IDs %v", received)
+	}
+	// NOTE: 之后在线生成的批次接着数据集的序号编号
+	if next := s.nextSequenceID(0); next <= 3 {
+		t.Fatalf("sequence ID %d reused after replay", next)
+	}
+	// NOTE: 之后在线生成的交易接着数据集的 nonce 与余额
+	for i, entry := range s.Ledger.Entries(0) {
+		if entry != generated[i] {
+			t.Fatalf("ledger after replay has %+v, want %+v", entry, generated[i])
+		}
+	}
+	loaded, err := s.Store.Load()
+	if err != nil || len(loaded[0]) != len(generated) {
+		t.Fatalf("stored %d accounts after replay (%v)", len(loaded[0]), err)
+	}
+	for i, acc := range loaded[0] {
+		if acc.Nonce != generated[i].Nonce || acc.Balance != generated[i].Balance {
+			t.Fatalf("stored account after replay %+v, want %+v", acc, generated[i])
+		}
+	}
+}
+
+func TestPersistCrossShardCredits(t *testing.T) {
+	for _, mode := range []string{"interval=10ms&number=5", "mode=poisson&tps=500"} {
+		s, base, _ := newTestServer(t, 2)
+		for shardID := 0; shardID < 2; shardID++ {
+			if code := call(t, http.MethodPost, base+fmt.Sprintf("/generate_account?shard_id=%d&acc_number=10&seed=%d", shardID, shardID+1), nil); code != http.StatusOK {
+				t.Fatalf("generate accounts for shard %d: status %d", shardID, code)
+			}
+		}
+		var status JobStatus
+		if code := call(t, http.MethodPost, base+"/generate_transaction?shard_id=0&cross_shard_ratio=100&"+mode, &status); code != http.StatusOK {
+			t.Fatalf("%s: start job: status %d", mode, code)
+		}
+		pending := func() int64 {
+			var sum int64
+			for _, entry := range s.Ledger.Entries(1) {
+				sum += entry.Pending
+			}
+			return sum
+		}
+		waitFor(t, "cross-shard credits", func() bool { return pending() > 0 })
+		if code := call(t, http.MethodPost, base+"/jobs/stop?id="+status.ID, nil); code != http.StatusOK {
+			t.Fatalf("%s: stop job: status %d", mode, code)
+		}
+		// NOTE: 目标 shard 上的待入账金额与源 shard 一起保存, 重启后不会丢失
+		stored := func() int64 {
+			loaded, err := s.Store.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sum int64
+			for _, acc := range loaded[1] {
+				sum += acc.Pending
+			}
+			return sum
+		}
+		waitFor(t, "stored credits to match the ledger", func() bool { return stored() == pending() })
+	}
+}
+
+// brokenStore 的 Load 总是失败
+type brokenStore struct{ *store.MemoryStore }
+
+func (brokenStore) Load() (map[int][]types.Account, error) {
+	return nil, errors.New("corrupted state")
+}
+
+func TestNewServerWithBrokenStore(t *testing.T) {
+	s := NewServer(config.Default(), brokenStore{store.NewMemoryStore()})
+	generatorServer := httptest.NewServer(s.mux)
+	defer generatorServer.Close()
+	for _, path := range []string{"/partition", "/partition?address=0xaa&to=0xbb"} {
+		resp, err := http.Get(generatorServer.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: status %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestGenerateAccountsMnemonic(t *testing.T) {
+	stub := &shardStub{}
+	shard := httptest.NewServer(stub)
+	defer shard.Close()
+	cfg := config.Default()
+	cfg.ShardsTable = map[string]string{"Shard_0": shard.URL}
+	s := NewServer(cfg, store.NewMemoryStore())
+	generatorServer := httptest.NewServer(s.Handler())
+	defer generatorServer.Close()
+
+	const mnemonic = "test test test test test test test test test test test junk"
+	post := func(query string, body url.Values) int {
+		resp, err := http.PostForm(generatorServer.URL+"/generate_account?shard_id=0&acc_number=1"+query, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := post("&mnemonic="+url.QueryEscape(mnemonic), nil); code != http.StatusBadRequest {
+		t.Fatalf("mnemonic in the query string: status %d", code)
+	}
+	// NOTE: 最后一个单词改变后校验和不再匹配
+	if code := post("", url.Values{"mnemonic": {"test test test test test test test test test test test test"}}); code != http.StatusBadRequest {
+		t.Fatalf("mnemonic with a bad checksum: status %d", code)
+	}
+	if code := post("", url.Values{"mnemonic": {mnemonic}}); code != http.StatusOK {
+		t.Fatalf("mnemonic in the body: status %d", code)
+	}
+	if accounts := s.addressMap()[0]; len(accounts) != 1 || accounts[0].Address != "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266" {
+		t.Fatalf("accounts not derived from the mnemonic: %+v", accounts)
+	}
+}
+
+func TestValueHistogramInDataDir(t *testing.T) {
+	cfg := config.Default()
+	cfg.DataDir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(cfg.DataDir, "values.csv"), []byte("value,weight\n5,1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(cfg, store.NewMemoryStore())
+	for path, ok := range map[string]bool{
+		"values.csv":                             true,
+		"../values.csv":                          false,
+		"nested/../../values.csv":                false,
+		filepath.Join(cfg.DataDir, "values.csv"): false,
+	} {
+		params := url.Values{"value_dist": {"histogram"}, "value_histogram": {path}}
+		_, err := s.jobConfig(0, params)
+		if ok && err != nil {
+			t.Fatalf("histogram %s: %v", path, err)
+		}
+		if !ok && !errors.Is(err, errInvalidParam) {
+			t.Fatalf("histogram %s outside the data directory accepted: %v", path, err)
+		}
+	}
+}
+
+func TestReplayTraceOutsideDataDir(t *testing.T) {
+	// NOTE: 数据目录之外的 trace 文件本身是合法的, 只能因为路径被拒绝
+	dir := t.TempDir()
+	trace := filepath.Join(dir, "trace.csv")
+	if err := os.WriteFile(trace, []byte("from,to,value,block\n0xaa,0xbb,1,1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	cfg.DataDir = filepath.Join(dir, "data")
+	s := NewServer(cfg, store.NewMemoryStore())
+	generatorServer := httptest.NewServer(s.Handler())
+	defer generatorServer.Close()
+	for _, path := range []string{"../trace.csv", trace} {
+		resp, err := http.Post(generatorServer.URL+"/replay_trace?path="+url.QueryEscape(path), "application/json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("trace %s: status %d", path, resp.StatusCode)
+		}
+	}
+}
+
+// writeEmptyDataset 在 dir 中写入只包含 shard 0 的一个空批次的数据集
+func writeEmptyDataset(t *testing.T, dir string) {
+	t.Helper()
+	w, err := dataset.Create(dataset.BatchesPath(dir, 0, dataset.Binary), dataset.Binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]byte(`{"sequence_id":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if err := dataset.WriteManifest(dir, dataset.Manifest{Format: dataset.Binary, Shards: []int{0}, Batches: map[int]int{0: 1}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayDatasetOutsideDataDir(t *testing.T) {
+	// NOTE: 数据目录内外各放一份数据集
+	dir := t.TempDir()
+	writeEmptyDataset(t, filepath.Join(dir, "set"))
+	writeEmptyDataset(t, filepath.Join(dir, "data", "set"))
+
+	s, base, _ := newTestServer(t, 1)
+	s.Config.DataDir = filepath.Join(dir, "data")
+	for path, want := range map[string]int{
+		"../set":                  http.StatusBadRequest,
+		filepath.Join(dir, "set"): http.StatusBadRequest,
+		"set":                     http.StatusOK,
+	} {
+		if code := call(t, http.MethodPost, base+"/replay_dataset?path="+url.QueryEscape(path), nil); code != want {
+			t.Fatalf("dataset %s: status %d, want %d", path, code, want)
+		}
+	}
+}
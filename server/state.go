@@ -0,0 +1,103 @@
+package server
+
+import (
+	"generator_boilerplate/generator"
+	"generator_boilerplate/types"
+	"strings"
+)
+
+// addressMap 返回当前按照账户归属分组的账户, 调用方不能修改返回的 map
+func (s *Server) addressMap() map[int][]types.Account {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	return s.AddressMap
+}
+
+// partitionView 返回当前按照划分策略分组的账户以及划分策略, 调用方不能修改返回的 map
+func (s *Server) partitionView() (map[int][]types.Account, generator.Partitioner) {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	return s.view, s.Partitioner
+}
+
+// refreshPartition 在账户或 shard 拓扑变化后重建划分策略与账户视图
+func (s *Server) refreshPartition() error {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	return s.refreshPartitionLocked()
+}
+
+// refreshPartitionLocked 与 refreshPartition 相同, 调用方需持有 s.stateMu 的写锁
+func (s *Server) refreshPartitionLocked() error {
+	spec := generator.PartitionSpec{
+		Strategy:     s.Config.Partitioner,
+		VirtualNodes: s.Config.VirtualNodes,
+		File:         s.Config.PartitionFile,
+	}
+	partitioner, err := spec.Build(s.Shards.IDs(), s.AddressMap)
+	if err != nil {
+		// NOTE: 构造失败时退化为按照账户的归属划分
+		partitioner = generator.NewMembership(s.AddressMap)
+	}
+	s.Partitioner = generator.WithOverrides(partitioner, s.migrated)
+	s.view = generator.PartitionAccounts(s.Partitioner, s.AddressMap)
+	return err
+}
+
+// setShardAccounts 替换 shard 的账户并重建划分视图
+func (s *Server) setShardAccounts(shardID int, accounts []types.Account) error {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	addressMap := make(map[int][]types.Account, len(s.AddressMap)+1)
+	for id, existing := range s.AddressMap {
+		addressMap[id] = existing
+	}
+	addressMap[shardID] = accounts
+	s.AddressMap = addressMap
+	return s.refreshPartitionLocked()
+}
+
+// moveAccounts 将账户从所在的 AddressMap 项移动到 to 并重建划分视图, 返回账户数发生变化的 shard
+func (s *Server) moveAccounts(accounts []types.Account, to int) (map[int]bool, error) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	moved := make(map[string]bool, len(accounts))
+	// NOTE: WithOverrides 会复制 migrated, 已经发布的划分策略不受这里的修改影响
+	for _, acc := range accounts {
+		moved[acc.Address] = true
+		s.migrated[strings.ToLower(acc.Address)] = to
+	}
+	addressMap := make(map[int][]types.Account, len(s.AddressMap)+1)
+	changed := map[int]bool{to: true}
+	for shardID, existing := range s.AddressMap {
+		kept := make([]types.Account, 0, len(existing))
+		for _, acc := range existing {
+			if moved[acc.Address] {
+				changed[shardID] = true
+				continue
+			}
+			kept = append(kept, acc)
+		}
+		addressMap[shardID] = kept
+	}
+	addressMap[to] = append(addressMap[to], accounts...)
+	s.AddressMap = addressMap
+	return changed, s.refreshPartitionLocked()
+}
+
+// nextSequenceID 返回发往 shard 的下一个消息序号, 每个 shard 从 1 开始
+func (s *Server) nextSequenceID(shardID int) int64 {
+	s.sequenceMu.Lock()
+	defer s.sequenceMu.Unlock()
+	s.sequences[shardID]++
+	return s.sequences[shardID]
+}
+
+// reserveSequenceID 保证之后分配给 shard 的序号大于 sequenceID, 用于回放数据集中已经编号的批次
+func (s *Server) reserveSequenceID(shardID int, sequenceID int64) {
+	s.sequenceMu.Lock()
+	defer s.sequenceMu.Unlock()
+	if sequenceID > s.sequences[shardID] {
+		s.sequences[shardID] = sequenceID
+	}
+}
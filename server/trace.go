@@ -0,0 +1,186 @@
+package server
+
+import (
+	"generator_boilerplate/constant"
+	"generator_boilerplate/generator"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// traceJobShard 是 trace 回放与数据集回放 job 的 ShardID, 回放会同时向所有 shard 发送交易
+// NOTE: 二者的 mode 不同, 可以同时运行
+const traceJobShard = -1
+
+// TraceReplay 描述一次 trace 回放
+type TraceReplay struct {
+	Path    string
+	Records []generator.TraceRecord
+	// NOTE: 区块间隔被除以 Speedup, 大于 1 时压缩时间
+	Speedup float64
+	// NOTE: trace 中没有时间戳时, 按区块号之差乘以 BlockInterval 计算区块间隔
+	BlockInterval time.Duration
+}
+
+// delay 返回两个区块之间需要等待的时间
+func (t *TraceReplay) delay(prev, next generator.TraceRecord) time.Duration {
+	var gap time.Duration
+	if prev.Timestamp > 0 && next.Timestamp > 0 {
+		gap = time.Duration(next.Timestamp-prev.Timestamp) * time.Second
+	} else {
+		gap = time.Duration(next.Block-prev.Block) * t.BlockInterval
+	}
+	if gap <= 0 {
+		return 0
+	}
+	return time.Duration(float64(gap) / t.Speedup)
+}
+
+// runTraceJob 按区块回放 trace, 每个区块为每个发送方 shard 打包一个 RequestMsg
+func (s *Server) runTraceJob(job *Job) {
+	trace := job.Trace
+	view, partitioner := s.partitionView()
+	mapper, err := generator.NewTraceMapper(view, partitioner, s.Shards.IDs())
+	if err != nil {
+		log.Printf("[ERROR] Job %s: %v", job.ID, err)
+		job.recordFailure(err)
+		_ = job.Stop()
+		return
+	}
+	for start := 0; start < len(trace.Records); {
+		end := start
+		for end < len(trace.Records) && trace.Records[end].Block == trace.Records[start].Block {
+			end++
+		}
+		if start > 0 && !job.wait(trace.delay(trace.Records[start-1], trace.Records[start])) {
+			log.Printf("Job %s for trace %s stopped.", job.ID, trace.Path)
+			return
+		}
+		s.replayBlock(job, mapper, trace.Records[start:end])
+		start = end
+	}
+	log.Printf("Job %s finished replaying trace %s.", job.ID, trace.Path)
+	_ = job.Stop()
+}
+
+// replayBlock 将一个区块内的交易映射到生成的账户上并发送, 余额不足或映射到同一账户的交易会被跳过
+func (s *Server) replayBlock(job *Job, mapper *generator.TraceMapper, records []generator.TraceRecord) {
+	batches := make(map[int][]interface{})
+	shards := make([]int, 0)
+	skipped := 0
+	for _, record := range records {
+		from, to := mapper.Map(record.From), mapper.Map(record.To)
+		// NOTE: 与 Options.value 相同, 金额不能超过扣除 gas 费用后的余额
+		balance, _ := s.Ledger.Balance(from.Account.Address)
+		balance -= job.Options.Fee()
+		if from.Account.Address == to.Account.Address || balance < 1 {
+			reason := "same_account"
+			if balance < 1 {
+				reason = generator.RejectionReason(generator.ErrInsufficientBalance)
+			}
+			s.Metrics.rejections.Inc(shardLabel(from.ShardID), reason)
+			job.recordRejection(reason)
+			skipped++
+			continue
+		}
+		value := record.Value
+		if value < 1 {
+			value = 1
+		}
+		if value > balance {
+			value = balance
+		}
+		var tx interface{}
+		var err error
+		if from.ShardID == to.ShardID {
+			tx, err = generator.BuildTransaction(job.Options, from.Account, to.Account, value)
+		} else {
+			tx, err = generator.BuildCrossShardTransaction(job.Options, from.ShardID, from.Account, to.Account, value)
+		}
+		if err != nil {
+			log.Printf("[ERROR] Job %s: replay %s -> %s: %v", job.ID, record.From, record.To, err)
+			reason := generator.RejectionReason(err)
+			s.Metrics.rejections.Inc(shardLabel(from.ShardID), reason)
+			job.recordRejection(reason)
+			skipped++
+			continue
+		}
+		s.touchAccounts(job, from.Account.Address, to.Account.Address)
+		if _, ok := batches[from.ShardID]; !ok {
+			shards = append(shards, from.ShardID)
+		}
+		batches[from.ShardID] = append(batches[from.ShardID], tx)
+	}
+	job.recordSkipped(skipped)
+	s.saveJobShards(job)
+	for _, shardID := range shards {
+		msg := s.newRequestMsg(shardID, batches[shardID], false, job.Codec)
+		err := s.submitBatch(shardID, msg)
+		if err != nil {
+			log.Printf("[ERROR] Job %s: %v", job.ID, err)
+		}
+		job.recordBatch(msg, err)
+	}
+}
+
+func (s *Server) handleReplayTrace(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+	trace := &TraceReplay{Path: params.Get("path"), Speedup: 1, BlockInterval: constant.TraceBlockInterval}
+	if trace.Path == "" {
+		http.Error(w, "Missing path", http.StatusBadRequest)
+		return
+	}
+	var err error
+	if param := params.Get("speedup"); param != "" {
+		if trace.Speedup, err = strconv.ParseFloat(param, 64); err != nil || trace.Speedup <= 0 {
+			http.Error(w, "Invalid speedup", http.StatusBadRequest)
+			return
+		}
+	}
+	if param := params.Get("block_interval"); param != "" {
+		if trace.BlockInterval, err = time.ParseDuration(param); err != nil || trace.BlockInterval < 0 {
+			http.Error(w, "Invalid block_interval", http.StatusBadRequest)
+			return
+		}
+	}
+	codec, err := s.codec(params)
+	if err != nil {
+		http.Error(w, "Invalid codec", http.StatusBadRequest)
+		return
+	}
+	ethereum, err := s.ethereumConfig(params, codec)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	path, err := s.dataPath(trace.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if trace.Records, err = generator.LoadTrace(path); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cfg := JobConfig{
+		ShardID:  traceJobShard,
+		Mode:     ModeTrace,
+		Interval: s.Config.GenerationInterval.Duration,
+		Number:   len(trace.Records),
+		Options:  generator.Options{Ledger: s.Ledger, Ethereum: ethereum},
+		Trace:    trace,
+		Codec:    codec,
+	}
+	job, err := s.startJob(cfg, false, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	log.Printf("Started job %s replaying %d records from %s.", job.ID, len(trace.Records), trace.Path)
+	writeJSON(w, job.Status())
+}
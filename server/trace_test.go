@@ -0,0 +1,127 @@
+package server
+
+import (
+	"fmt"
+	"generator_boilerplate/types"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplayTrace(t *testing.T) {
+	s, base, stubs := newTestServer(t, 2)
+	s.Config.DataDir = t.TempDir()
+	for shardID := 0; shardID < 2; shardID++ {
+		if code := call(t, http.MethodPost, fmt.Sprintf("%s/generate_account?shard_id=%d&acc_number=5&seed=1", base, shardID), nil); code != http.StatusOK {
+			t.Fatalf("generate accounts for shard %d: status %d", shardID, code)
+		}
+	}
+	trace := "block,from,to,value\n" +
+		"1,0x01,0x02,5\n" +
+		"1,0x03,0x04,7\n" +
+		"2,0x02,0x05,3\n" +
+		"3,0x06,0x01,2\n" +
+		"3,0x07,0x07,2\n"
+	if err := os.WriteFile(filepath.Join(s.Config.DataDir, "trace.csv"), []byte(trace), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var status JobStatus
+	if code := call(t, http.MethodPost, base+"/replay_trace?path=trace.csv&block_interval=1ms", &status); code != http.StatusOK {
+		t.Fatalf("replay trace: status %d", code)
+	}
+	if status.Mode != ModeTrace || status.Trace != "trace.csv" {
+		t.Fatalf("unexpected trace job %+v", status)
+	}
+	waitFor(t, "the trace replay to finish", func() bool {
+		call(t, http.MethodGet, base+"/jobs/status?id="+status.ID, &status)
+		return status.State == JobStopped
+	})
+
+	batches, txs := 0, 0
+	for _, stub := range stubs {
+		for _, msg := range stub.receivedRequests() {
+			batches++
+			txs += len(msg.Transactions) + len(msg.CrossShardTransactions)
+		}
+	}
+	// NOTE: 最后一条记录的发送方与接收方相同, 被跳过
+	if status.TxsSent != 4 || status.Skipped != 1 || txs != status.TxsSent || batches != status.BatchesSent || status.Failures != 0 {
+		t.Fatalf("shards received %d transactions in %d batches, job reports %+v", txs, batches, status)
+	}
+	// NOTE: 每个区块为每个发送方 shard 最多发送一个批次
+	if batches < 3 || batches > 6 {
+		t.Fatalf("%d batches for 3 blocks over 2 shards", batches)
+	}
+}
+
+func TestReplayTraceEthereum(t *testing.T) {
+	s, base, stubs := newTestServer(t, 2)
+	s.Config.DataDir = t.TempDir()
+	// NOTE: 余额足够支付最大 gas 费用, 但低于 trace 中以 wei 计的金额
+	s.Config.Balance = 1e15
+	for shardID := 0; shardID < 2; shardID++ {
+		if code := call(t, http.MethodPost, fmt.Sprintf("%s/generate_account?shard_id=%d&acc_number=5&seed=1", base, shardID), nil); code != http.StatusOK {
+			t.Fatalf("generate accounts for shard %d: status %d", shardID, code)
+		}
+	}
+	trace := "block,from,to,value\n" +
+		"1,0x01,0x02,1000000000000000000\n" +
+		"1,0x03,0x04,1000000000000000000\n" +
+		"2,0x05,0x06,1000000000000000000\n"
+	if err := os.WriteFile(filepath.Join(s.Config.DataDir, "trace.csv"), []byte(trace), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var status JobStatus
+	if code := call(t, http.MethodPost, base+"/replay_trace?path=trace.csv&block_interval=1ms&tx_type=legacy", &status); code != http.StatusOK {
+		t.Fatalf("replay trace: status %d", code)
+	}
+	waitFor(t, "the trace replay to finish", func() bool {
+		call(t, http.MethodGet, base+"/jobs/status?id="+status.ID, &status)
+		return status.State == JobStopped
+	})
+	// NOTE: 金额被限制为扣除 gas 费用后的余额, 账本不会因为余额不足拒绝
+	if status.TxsSent != 3 || status.Skipped != 0 || len(status.Rejections) != 0 {
+		t.Fatalf("job reports %+v", status)
+	}
+	for _, stub := range stubs {
+		for _, msg := range stub.receivedRequests() {
+			for _, content := range msg.Transactions {
+				var tx types.Transaction
+				if err := msg.Codec.Decode(content, &tx); err != nil || len(tx.Raw) == 0 || tx.Value >= s.Config.Balance {
+					t.Fatalf("transaction %+v (%v)", tx, err)
+				}
+			}
+		}
+	}
+}
+
+func TestTraceAndDatasetReplayConcurrently(t *testing.T) {
+	s, base, _ := newTestServer(t, 1)
+	s.Config.DataDir = t.TempDir()
+	if code := call(t, http.MethodPost, base+"/generate_account?shard_id=0&acc_number=5&seed=1", nil); code != http.StatusOK {
+		t.Fatalf("generate accounts: status %d", code)
+	}
+	// NOTE: 两个区块相隔一小时, 测试期间 trace 回放一直处于运行状态
+	trace := "block,from,to,value\n1,0x01,0x02,1\n2,0x02,0x01,1\n"
+	if err := os.WriteFile(filepath.Join(s.Config.DataDir, "trace.csv"), []byte(trace), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writeEmptyDataset(t, filepath.Join(s.Config.DataDir, "set"))
+	if code := call(t, http.MethodPost, base+"/replay_trace?path=trace.csv&block_interval=1h", nil); code != http.StatusOK {
+		t.Fatalf("replay trace: status %d", code)
+	}
+	var status JobStatus
+	if code := call(t, http.MethodPost, base+"/replay_dataset?path=set&accounts=false&rate=1000", &status); code != http.StatusOK {
+		t.Fatalf("replay dataset during a trace replay: status %d", code)
+	}
+	waitFor(t, "the dataset replay to finish", func() bool {
+		call(t, http.MethodGet, base+"/jobs/status?id="+status.ID, &status)
+		return status.State == JobStopped
+	})
+	if code := call(t, http.MethodPost, base+"/replay_trace?path=trace.csv&block_interval=1h", nil); code != http.StatusConflict {
+		t.Fatalf("second trace replay: status %d", code)
+	}
+}
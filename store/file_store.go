@@ -0,0 +1,87 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"generator_boilerplate/types"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const shardFilePrefix = "shard_"
+
+// FileStore 将每个 shard 的账户以 JSON 形式保存在 Dir/shard_<id>.json 中
+type FileStore struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{Dir: dir}, nil
+}
+
+func (f *FileStore) path(shardID int) string {
+	return filepath.Join(f.Dir, fmt.Sprintf("%s%d.json", shardFilePrefix, shardID))
+}
+
+func (f *FileStore) Save(shardID int, accounts []types.Account) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := json.Marshal(accounts)
+	if err != nil {
+		return err
+	}
+	// NOTE: 先写临时文件再 rename, 避免进程中途退出留下不完整的状态文件
+	tmp, err := os.CreateTemp(f.Dir, shardFilePrefix+"*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(shardID))
+}
+
+func (f *FileStore) Load() (map[int][]types.Account, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries, err := os.ReadDir(f.Dir)
+	if err != nil {
+		return nil, err
+	}
+	loaded := make(map[int][]types.Account)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, shardFilePrefix) || filepath.Ext(name) != ".json" {
+			continue
+		}
+		shardID, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, shardFilePrefix), ".json"))
+		if err != nil {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(f.Dir, name))
+		if err != nil {
+			return nil, err
+		}
+		accounts := make([]types.Account, 0)
+		if err := json.Unmarshal(content, &accounts); err != nil {
+			return nil, fmt.Errorf("load %s: %w", name, err)
+		}
+		loaded[shardID] = accounts
+	}
+	return loaded, nil
+}
@@ -0,0 +1,34 @@
+package store
+
+import (
+	"generator_boilerplate/types"
+	"reflect"
+	"testing"
+)
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	st, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts := []types.Account{
+		{PrivateKey: "0x01", Address: "0xaa", Balance: 10, Nonce: 3},
+		{PrivateKey: "0x02", Address: "0xbb", Balance: 20, Nonce: 0, Pending: 5},
+	}
+	if err := st.Save(2, accounts); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := reopened.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 1 || !reflect.DeepEqual(loaded[2], accounts) {
+		t.Fatalf("unexpected state after reload: %+v", loaded)
+	}
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"generator_boilerplate/types"
+	"sync"
+)
+
+// Store 持久化每个 shard 的账户状态 (账户, 余额, nonce), 用于生成器重启后恢复
+type Store interface {
+	Save(shardID int, accounts []types.Account) error
+	Load() (map[int][]types.Account, error)
+}
+
+// MemoryStore 仅在进程内保存状态, 不做持久化
+type MemoryStore struct {
+	mu       sync.Mutex
+	accounts map[int][]types.Account
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{accounts: make(map[int][]types.Account)}
+}
+
+func (m *MemoryStore) Save(shardID int, accounts []types.Account) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accounts[shardID] = append([]types.Account(nil), accounts...)
+	return nil
+}
+
+func (m *MemoryStore) Load() (map[int][]types.Account, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	loaded := make(map[int][]types.Account, len(m.accounts))
+	for shardID, accounts := range m.accounts {
+		loaded[shardID] = append([]types.Account(nil), accounts...)
+	}
+	return loaded, nil
+}
@@ -1,7 +1,12 @@
 package types
 
 import (
+	"crypto/ecdsa"
 	"encoding/json"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 type Account struct {
@@ -9,16 +14,39 @@ type Account struct {
 	Address    string `json:"address"`
 	Balance    int64  `json:"balance"`
 	Nonce      int64  `json:"nonce"`
+	// NOTE: 持久化时记录账本中跨片转入但尚未入账的金额, 生成的账户为 0
+	Pending int64 `json:"pending,omitempty"`
+}
+
+// ECDSA 解析账户的十六进制私钥
+func (a *Account) ECDSA() (*ecdsa.PrivateKey, error) {
+	return crypto.HexToECDSA(strings.TrimPrefix(a.PrivateKey, "0x"))
 }
 
-//func (a *Account) RLPEncode() ([]byte, error) {
-//	encoded, err := rlp.EncodeToBytes(a)
-//	fmt.Println(string(encoded))
-//	if err != nil {
-//		return nil, err
-//	}
-//	return encoded, nil
-//}
+// rlpAccount 是 Account 的 RLP 编码形式, RLP 只支持无符号整数
+type rlpAccount struct {
+	PrivateKey string
+	Address    string
+	Balance    uint64
+	Nonce      uint64
+	Pending    uint64 `rlp:"optional"`
+}
+
+func (a *Account) RLPEncode() ([]byte, error) {
+	balance, err := toRLPUint("balance", a.Balance)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := toRLPUint("nonce", a.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := toRLPUint("pending", a.Pending)
+	if err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(&rlpAccount{PrivateKey: a.PrivateKey, Address: a.Address, Balance: balance, Nonce: nonce, Pending: pending})
+}
 
 func (a *Account) Marshal() ([]byte, error) {
 	encoded, err := json.Marshal(a)
@@ -28,13 +56,26 @@ func (a *Account) Marshal() ([]byte, error) {
 	return encoded, nil
 }
 
-//func (a *Account) RLPDecode(content []byte) error {
-//	err := rlp.DecodeBytes(content, a)
-//	if err != nil {
-//		return err
-//	}
-//	return nil
-//}
+func (a *Account) RLPDecode(content []byte) error {
+	var decoded rlpAccount
+	if err := rlp.DecodeBytes(content, &decoded); err != nil {
+		return err
+	}
+	balance, err := fromRLPUint("balance", decoded.Balance)
+	if err != nil {
+		return err
+	}
+	nonce, err := fromRLPUint("nonce", decoded.Nonce)
+	if err != nil {
+		return err
+	}
+	pending, err := fromRLPUint("pending", decoded.Pending)
+	if err != nil {
+		return err
+	}
+	*a = Account{PrivateKey: decoded.PrivateKey, Address: decoded.Address, Balance: balance, Nonce: nonce, Pending: pending}
+	return nil
+}
 
 func (a *Account) Unmarshal(content []byte) error {
 	err := json.Unmarshal(content, a)
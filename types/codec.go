@@ -0,0 +1,98 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Codec 决定 AccountsMsg / RequestMsg / MigrationMsg 中每个账户或交易的编码方式,
+// 消息本身始终使用 json
+type Codec string
+
+const (
+	CodecJSON Codec = "json"
+	CodecRLP  Codec = "rlp"
+	// NOTE: 交易编码为签名后的以太坊交易 (见 Transaction.Raw), 跨片交易额外携带 ShardID 与 Proof,
+	// 账户等其他类型使用 rlp
+	CodecEthereum Codec = "eth"
+)
+
+// ErrNegativeInteger 表示有符号字段为负数, RLP 只能编码非负整数
+var ErrNegativeInteger = errors.New("rlp cannot encode negative integers")
+
+// Encoding 是可以使用任一 Codec 编解码的类型
+type Encoding interface {
+	Marshal() ([]byte, error)
+	Unmarshal(content []byte) error
+	RLPEncode() ([]byte, error)
+	RLPDecode(content []byte) error
+}
+
+// EthereumEncoding 是可以编码为签名后的以太坊交易的类型
+type EthereumEncoding interface {
+	EthereumEncode() ([]byte, error)
+	EthereumDecode(content []byte) error
+}
+
+// ParseCodec 解析编码名称, 空字符串表示 json (兼容没有 codec 字段的消息)
+func ParseCodec(name string) (Codec, error) {
+	switch codec := Codec(name); codec {
+	case "":
+		return CodecJSON, nil
+	case CodecJSON, CodecRLP, CodecEthereum:
+		return codec, nil
+	default:
+		return "", fmt.Errorf("unknown codec %q", name)
+	}
+}
+
+func (c Codec) Encode(v Encoding) ([]byte, error) {
+	switch c {
+	case CodecRLP:
+		return v.RLPEncode()
+	case CodecEthereum:
+		if tx, ok := v.(EthereumEncoding); ok {
+			return tx.EthereumEncode()
+		}
+		return v.RLPEncode()
+	}
+	return v.Marshal()
+}
+
+func (c Codec) Decode(content []byte, v Encoding) error {
+	switch c {
+	case CodecRLP:
+		return v.RLPDecode(content)
+	case CodecEthereum:
+		if tx, ok := v.(EthereumEncoding); ok {
+			return tx.EthereumDecode(content)
+		}
+		return v.RLPDecode(content)
+	}
+	return v.Unmarshal(content)
+}
+
+// toRLPUint 将有符号字段转换为 RLP 使用的 uint64
+func toRLPUint(field string, v int64) (uint64, error) {
+	if v < 0 {
+		return 0, fmt.Errorf("%s %d: %w", field, v, ErrNegativeInteger)
+	}
+	return uint64(v), nil
+}
+
+// rlpBytes 把解码得到的空字节串还原为 nil, 与 json 编码 (omitempty) 的结果保持一致
+func rlpBytes(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	return b
+}
+
+// fromRLPUint 将解码得到的 uint64 转换回有符号字段
+func fromRLPUint(field string, v uint64) (int64, error) {
+	if v > math.MaxInt64 {
+		return 0, fmt.Errorf("%s %d overflows int64", field, v)
+	}
+	return int64(v), nil
+}
@@ -0,0 +1,77 @@
+package types
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestRLPRoundTrip(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := crypto.PubkeyToAddress(key.PublicKey).Hex()
+
+	tx := NewTransaction(from, "0xto", 7, 3)
+	if err := tx.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewCrossShardTransaction(2, from, "0xto", 9, 4)
+	if err := ctx.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.SetProof(MerkleProof{Index: 1, Siblings: [][]byte{{1, 2}, {3}}}); err != nil {
+		t.Fatal(err)
+	}
+	committed := NewTransaction(from, "0xto", 1, 5)
+	committed.Receipt = Receipt{Status: true, Hash: []byte{0xaa}, CommittedAt: 1700000000000000000, BlockHeight: 12}
+
+	cases := []struct {
+		v       Encoding
+		decoded Encoding
+		fields  int
+	}{
+		{&Account{PrivateKey: "0x01", Address: from, Balance: 100, Nonce: 2}, &Account{}, 4},
+		{&Receipt{Status: true, Hash: []byte{1}, CommittedAt: 5, BlockHeight: 6}, &Receipt{}, 4},
+		{&tx, &Transaction{}, 9},
+		{&committed, &Transaction{}, 9},
+		{&ctx, &CrossShardTransaction{}, 11},
+	}
+	for _, c := range cases {
+		encoded, err := CodecRLP.Encode(c.v)
+		if err != nil {
+			t.Fatalf("%T: %v", c.v, err)
+		}
+		// NOTE: 编码结果是一个 RLP 列表, 可以被不了解具体类型的 rlp 解码器解析
+		var fields []rlp.RawValue
+		if err := rlp.DecodeBytes(encoded, &fields); err != nil || len(fields) != c.fields {
+			t.Fatalf("%T: decoded %d generic fields, want %d (%v)", c.v, len(fields), c.fields, err)
+		}
+		if err := CodecRLP.Decode(encoded, c.decoded); err != nil {
+			t.Fatalf("%T: %v", c.v, err)
+		}
+		if !reflect.DeepEqual(c.v, c.decoded) {
+			t.Fatalf("%T: decoded %+v, want %+v", c.v, c.decoded, c.v)
+		}
+	}
+
+	decoded := &Transaction{}
+	content, _ := CodecRLP.Encode(&tx)
+	if err := CodecRLP.Decode(content, decoded); err != nil || decoded.Verify() != nil {
+		t.Fatalf("signature does not survive the round trip: %v", err)
+	}
+
+	if _, err := CodecRLP.Encode(&Account{Balance: -1}); !errors.Is(err, ErrNegativeInteger) {
+		t.Fatalf("negative balance encoded: %v", err)
+	}
+	if codec, err := ParseCodec(""); err != nil || codec != CodecJSON {
+		t.Fatalf("empty codec parsed as %q (%v)", codec, err)
+	}
+	if _, err := ParseCodec("xml"); err == nil {
+		t.Fatal("unknown codec accepted")
+	}
+}
@@ -1,8 +1,12 @@
 package types
 
 import (
+	"bytes"
+	"crypto/ecdsa"
 	"crypto/sha256"
 	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 type CrossShardTransaction struct {
@@ -13,8 +17,13 @@ type CrossShardTransaction struct {
 	Nonce   int64   `json:"nonce"`
 	Receipt Receipt `json:"receipt"`
 	Hash    []byte  `json:"hash"`
+	V       uint8   `json:"v,omitempty"`
+	R       []byte  `json:"r,omitempty"`
+	S       []byte  `json:"s,omitempty"`
 	// NOTE: proof 字段在填充前需要先 json 编码
 	Proof []byte `json:"proof"`
+	// NOTE: 以太坊格式时为签名后的以太坊交易, 见 Transaction.Raw
+	Raw []byte `json:"raw,omitempty"`
 }
 
 func NewCrossShardTransaction(shardID int, from, to string, value, nonce int64) CrossShardTransaction {
@@ -29,6 +38,15 @@ func NewCrossShardTransaction(shardID int, from, to string, value, nonce int64)
 }
 
 func (cst *CrossShardTransaction) GenerateTransactionHash() error {
+	hash, err := cst.computeHash()
+	if err != nil {
+		return err
+	}
+	cst.Hash = hash
+	return nil
+}
+
+func (cst *CrossShardTransaction) computeHash() ([]byte, error) {
 	txData := CrossShardTransaction{
 		ShardID: cst.ShardID,
 		From:    cst.From,
@@ -39,29 +57,129 @@ func (cst *CrossShardTransaction) GenerateTransactionHash() error {
 
 	data, err := json.Marshal(txData)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	hash := sha256.Sum256(data)
-	cst.Hash = hash[:]
+	return hash[:], nil
+}
+
+// Sign 使用发送方私钥对交易 hash 签名, 填充 V/R/S
+func (cst *CrossShardTransaction) Sign(privateKey *ecdsa.PrivateKey) error {
+	if err := cst.GenerateTransactionHash(); err != nil {
+		return err
+	}
+	v, r, s, err := signHash(cst.Hash, privateKey)
+	if err != nil {
+		return err
+	}
+	cst.V, cst.R, cst.S = v, r, s
 	return nil
 }
 
-//func (cst *CrossShardTransaction) RLPEncode() ([]byte, error) {
-//	encoded, err := rlp.EncodeToBytes(cst)
-//	if err != nil {
-//		return nil, err
-//	}
-//	return encoded, nil
-//}
-//
-//func (cst *CrossShardTransaction) RLPDecode(content []byte) error {
-//	err := rlp.DecodeBytes(content, cst)
-//	if err != nil {
-//		return nil
-//	}
-//	return nil
-//}
+// Verify 重新计算 hash 并从签名中恢复签名者, 检查其是否为 From
+func (cst *CrossShardTransaction) Verify() error {
+	if len(cst.Raw) > 0 {
+		return verifyEthereum(cst.Raw, cst.Hash, cst.From, cst.To, cst.Value, cst.Nonce)
+	}
+	hash, err := cst.computeHash()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(hash, cst.Hash) {
+		return ErrHashMismatch
+	}
+	signer, err := recoverAddress(hash, cst.V, cst.R, cst.S)
+	if err != nil {
+		return err
+	}
+	if signer != cst.From {
+		return ErrSignerMismatch
+	}
+	return nil
+}
+
+// rlpCrossShardTransaction 是 CrossShardTransaction 的 RLP 编码形式, Proof 仍然是 json 编码的 MerkleProof
+type rlpCrossShardTransaction struct {
+	ShardID uint64
+	From    string
+	To      string
+	Value   uint64
+	Nonce   uint64
+	Receipt rlpReceipt
+	Hash    []byte
+	V       uint8
+	R       []byte
+	S       []byte
+	Proof   []byte
+	Raw     []byte `rlp:"optional"`
+}
+
+func (cst *CrossShardTransaction) RLPEncode() ([]byte, error) {
+	shardID, err := toRLPUint("shard_id", int64(cst.ShardID))
+	if err != nil {
+		return nil, err
+	}
+	value, err := toRLPUint("value", cst.Value)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := toRLPUint("nonce", cst.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	receipt, err := cst.Receipt.toRLP()
+	if err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(&rlpCrossShardTransaction{
+		ShardID: shardID,
+		From:    cst.From,
+		To:      cst.To,
+		Value:   value,
+		Nonce:   nonce,
+		Receipt: receipt,
+		Hash:    cst.Hash,
+		V:       cst.V,
+		R:       cst.R,
+		S:       cst.S,
+		Proof:   cst.Proof,
+		Raw:     cst.Raw,
+	})
+}
+
+func (cst *CrossShardTransaction) RLPDecode(content []byte) error {
+	var decoded rlpCrossShardTransaction
+	if err := rlp.DecodeBytes(content, &decoded); err != nil {
+		return err
+	}
+	shardID, err := fromRLPUint("shard_id", decoded.ShardID)
+	if err != nil {
+		return err
+	}
+	value, err := fromRLPUint("value", decoded.Value)
+	if err != nil {
+		return err
+	}
+	nonce, err := fromRLPUint("nonce", decoded.Nonce)
+	if err != nil {
+		return err
+	}
+	*cst = CrossShardTransaction{
+		ShardID: int(shardID),
+		From:    decoded.From,
+		To:      decoded.To,
+		Value:   value,
+		Nonce:   nonce,
+		Hash:    rlpBytes(decoded.Hash),
+		V:       decoded.V,
+		R:       rlpBytes(decoded.R),
+		S:       rlpBytes(decoded.S),
+		Proof:   rlpBytes(decoded.Proof),
+		Raw:     rlpBytes(decoded.Raw),
+	}
+	return cst.Receipt.fromRLP(decoded.Receipt)
+}
 
 func (cst *CrossShardTransaction) Marshal() ([]byte, error) {
 	encoded, err := json.Marshal(cst)
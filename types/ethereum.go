@@ -0,0 +1,269 @@
+package types
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// TxType 是生成交易的格式, legacy 与 dynamic 生成签名后的以太坊交易
+type TxType string
+
+const (
+	TxNative TxType = "native"
+	// NOTE: 带有 EIP-155 chain id 的 legacy 交易
+	TxLegacy TxType = "legacy"
+	// NOTE: EIP-1559 动态费用交易
+	TxDynamic TxType = "dynamic"
+)
+
+// ParseTxType 解析交易格式名称
+func ParseTxType(name string) (TxType, error) {
+	switch txType := TxType(name); txType {
+	case TxNative, TxLegacy, TxDynamic:
+		return txType, nil
+	}
+	return "", fmt.Errorf("unknown tx type %q", name)
+}
+
+var (
+	ErrNotEthereum      = errors.New("transaction has no ethereum encoding")
+	ErrEthereumMismatch = errors.New("ethereum transaction does not match the transaction fields")
+)
+
+// EthereumConfig 是生成以太坊交易时使用的链与 gas 参数, gas 价格以 wei 为单位
+// NOTE: legacy 交易使用 GasPrice, dynamic 交易使用 GasTipCap 与 GasFeeCap
+type EthereumConfig struct {
+	Type      TxType
+	ChainID   *big.Int
+	GasLimit  uint64
+	GasPrice  *big.Int
+	GasTipCap *big.Int
+	GasFeeCap *big.Int
+}
+
+func (c EthereumConfig) Validate() error {
+	switch {
+	case c.Type != TxLegacy && c.Type != TxDynamic:
+		return fmt.Errorf("unknown ethereum transaction type %q", c.Type)
+	case c.ChainID == nil || c.ChainID.Sign() <= 0:
+		return errors.New("chain id must be positive")
+	case c.GasLimit < params.TxGas:
+		return fmt.Errorf("gas limit must be at least %d", params.TxGas)
+	case c.Type == TxLegacy && (c.GasPrice == nil || c.GasPrice.Sign() < 0):
+		return errors.New("gas price must not be negative")
+	case c.Type == TxDynamic && (c.GasTipCap == nil || c.GasFeeCap == nil || c.GasTipCap.Sign() < 0 || c.GasFeeCap.Cmp(c.GasTipCap) < 0):
+		return errors.New("gas fee cap must not be below the gas tip cap")
+	case !c.MaxFee().IsInt64():
+		return errors.New("gas limit times gas price overflows int64")
+	}
+	return nil
+}
+
+// MaxFee 返回一笔交易最多支付的 gas 费用: legacy 为 GasLimit * GasPrice, dynamic 为 GasLimit * GasFeeCap
+func (c EthereumConfig) MaxFee() *big.Int {
+	price := c.GasPrice
+	if c.Type == TxDynamic {
+		price = c.GasFeeCap
+	}
+	if price == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Mul(new(big.Int).SetUint64(c.GasLimit), price)
+}
+
+// sign 构造 to / value / nonce 对应的以太坊交易并签名, 返回签名后的编码 (legacy 为 RLP, dynamic 为 0x02 || RLP) 与交易 hash
+func (c EthereumConfig) sign(privateKey *ecdsa.PrivateKey, to string, value, nonce int64) ([]byte, []byte, error) {
+	if !common.IsHexAddress(to) {
+		return nil, nil, fmt.Errorf("invalid recipient %q", to)
+	}
+	amount, err := toRLPUint("value", value)
+	if err != nil {
+		return nil, nil, err
+	}
+	seq, err := toRLPUint("nonce", nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	recipient := common.HexToAddress(to)
+	var data gethtypes.TxData
+	switch c.Type {
+	case TxLegacy:
+		data = &gethtypes.LegacyTx{Nonce: seq, GasPrice: c.GasPrice, Gas: c.GasLimit, To: &recipient, Value: new(big.Int).SetUint64(amount)}
+	case TxDynamic:
+		data = &gethtypes.DynamicFeeTx{ChainID: c.ChainID, Nonce: seq, GasTipCap: c.GasTipCap, GasFeeCap: c.GasFeeCap, Gas: c.GasLimit, To: &recipient, Value: new(big.Int).SetUint64(amount)}
+	default:
+		return nil, nil, fmt.Errorf("unknown ethereum transaction type %q", c.Type)
+	}
+	// NOTE: LatestSignerForChainID 对 legacy 交易使用 EIP-155 签名
+	signed, err := gethtypes.SignNewTx(privateKey, gethtypes.LatestSignerForChainID(c.ChainID), data)
+	if err != nil {
+		return nil, nil, err
+	}
+	raw, err := signed.MarshalBinary()
+	if err != nil {
+		return nil, nil, err
+	}
+	return raw, signed.Hash().Bytes(), nil
+}
+
+// ethereumFields 是从签名后的以太坊交易中解析出的字段
+type ethereumFields struct {
+	from, to     string
+	value, nonce int64
+	hash         []byte
+}
+
+func decodeEthereum(raw []byte) (ethereumFields, error) {
+	var fields ethereumFields
+	tx := new(gethtypes.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return fields, err
+	}
+	if tx.To() == nil {
+		return fields, errors.New("contract creation is not a transfer")
+	}
+	if !tx.Value().IsInt64() {
+		return fields, fmt.Errorf("value %s overflows int64", tx.Value())
+	}
+	nonce, err := fromRLPUint("nonce", tx.Nonce())
+	if err != nil {
+		return fields, err
+	}
+	sender, err := gethtypes.Sender(gethtypes.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return fields, ErrInvalidSignature
+	}
+	fields = ethereumFields{from: sender.Hex(), to: tx.To().Hex(), value: tx.Value().Int64(), nonce: nonce, hash: tx.Hash().Bytes()}
+	return fields, nil
+}
+
+// ethereumFee 返回签名后的以太坊交易最多支付的 gas 费用, 与 EthereumConfig.MaxFee 的计算方式相同
+func ethereumFee(raw []byte) (int64, error) {
+	tx := new(gethtypes.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return 0, err
+	}
+	// NOTE: legacy 交易的 GasFeeCap 即 GasPrice
+	fee := new(big.Int).Mul(new(big.Int).SetUint64(tx.Gas()), tx.GasFeeCap())
+	if !fee.IsInt64() {
+		return 0, fmt.Errorf("max gas cost %s overflows int64", fee)
+	}
+	return fee.Int64(), nil
+}
+
+// verifyEthereum 检查签名后的以太坊交易与交易的字段一致, 且签名者为 from
+func verifyEthereum(raw, hash []byte, from, to string, value, nonce int64) error {
+	fields, err := decodeEthereum(raw)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(fields.hash, hash) {
+		return ErrHashMismatch
+	}
+	if fields.from != from {
+		return ErrSignerMismatch
+	}
+	if fields.to != common.HexToAddress(to).Hex() || fields.value != value || fields.nonce != nonce {
+		return ErrEthereumMismatch
+	}
+	return nil
+}
+
+// SignEthereum 使用发送方私钥签名对应的以太坊交易, Raw 为签名后的编码, Hash 为以太坊交易 hash
+// NOTE: 签名保存在 Raw 中, V/R/S 留空
+func (t *Transaction) SignEthereum(cfg EthereumConfig, privateKey *ecdsa.PrivateKey) error {
+	raw, hash, err := cfg.sign(privateKey, t.To, t.Value, t.Nonce)
+	if err != nil {
+		return err
+	}
+	t.Raw, t.Hash = raw, hash
+	t.V, t.R, t.S = 0, nil, nil
+	return nil
+}
+
+// Fee 返回交易在账本中扣除的 gas 费用, 原生格式的交易不支付 gas
+func (t *Transaction) Fee() (int64, error) {
+	if len(t.Raw) == 0 {
+		return 0, nil
+	}
+	return ethereumFee(t.Raw)
+}
+
+// EthereumEncode 返回签名后的以太坊交易, 可以直接用于 eth_sendRawTransaction
+func (t *Transaction) EthereumEncode() ([]byte, error) {
+	if len(t.Raw) == 0 {
+		return nil, ErrNotEthereum
+	}
+	return t.Raw, nil
+}
+
+func (t *Transaction) EthereumDecode(content []byte) error {
+	fields, err := decodeEthereum(content)
+	if err != nil {
+		return err
+	}
+	*t = Transaction{From: fields.from, To: fields.to, Value: fields.value, Nonce: fields.nonce, Hash: fields.hash, Raw: content}
+	return nil
+}
+
+func (cst *CrossShardTransaction) SignEthereum(cfg EthereumConfig, privateKey *ecdsa.PrivateKey) error {
+	raw, hash, err := cfg.sign(privateKey, cst.To, cst.Value, cst.Nonce)
+	if err != nil {
+		return err
+	}
+	cst.Raw, cst.Hash = raw, hash
+	cst.V, cst.R, cst.S = 0, nil, nil
+	return nil
+}
+
+func (cst *CrossShardTransaction) Fee() (int64, error) {
+	if len(cst.Raw) == 0 {
+		return 0, nil
+	}
+	return ethereumFee(cst.Raw)
+}
+
+// ethereumCrossShard 是跨片交易的以太坊编码: 签名后的以太坊交易之外还携带源 shard 与 json 编码的 Proof,
+// 目标 shard 需要二者才能验证并入账
+type ethereumCrossShard struct {
+	ShardID uint64
+	Raw     []byte
+	Proof   []byte
+}
+
+// EthereumEncode 返回 RLP 列表 [shard_id, raw, proof], raw 为签名后的以太坊交易
+func (cst *CrossShardTransaction) EthereumEncode() ([]byte, error) {
+	if len(cst.Raw) == 0 {
+		return nil, ErrNotEthereum
+	}
+	shardID, err := toRLPUint("shard_id", int64(cst.ShardID))
+	if err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(&ethereumCrossShard{ShardID: shardID, Raw: cst.Raw, Proof: cst.Proof})
+}
+
+func (cst *CrossShardTransaction) EthereumDecode(content []byte) error {
+	var wrapped ethereumCrossShard
+	if err := rlp.DecodeBytes(content, &wrapped); err != nil {
+		return err
+	}
+	shardID, err := fromRLPUint("shard_id", wrapped.ShardID)
+	if err != nil {
+		return err
+	}
+	fields, err := decodeEthereum(wrapped.Raw)
+	if err != nil {
+		return err
+	}
+	*cst = CrossShardTransaction{ShardID: int(shardID), From: fields.from, To: fields.to, Value: fields.value, Nonce: fields.nonce, Hash: fields.hash, Proof: rlpBytes(wrapped.Proof), Raw: wrapped.Raw}
+	return nil
+}
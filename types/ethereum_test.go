@@ -0,0 +1,133 @@
+package types
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestEthereumTransactions(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	other, _ := crypto.GenerateKey()
+	to := crypto.PubkeyToAddress(other.PublicKey).Hex()
+
+	configs := map[TxType]uint8{TxLegacy: gethtypes.LegacyTxType, TxDynamic: gethtypes.DynamicFeeTxType}
+	for txType, want := range configs {
+		cfg := EthereumConfig{
+			Type:      txType,
+			ChainID:   big.NewInt(1337),
+			GasLimit:  21000,
+			GasPrice:  big.NewInt(1e9),
+			GasTipCap: big.NewInt(1e9),
+			GasFeeCap: big.NewInt(2e9),
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		tx := NewTransaction(from, to, 42, 7)
+		if err := tx.SignEthereum(cfg, key); err != nil {
+			t.Fatalf("%s: %v", txType, err)
+		}
+		if err := tx.Verify(); err != nil {
+			t.Fatalf("%s: %v", txType, err)
+		}
+
+		// NOTE: Raw 可以被 go-ethereum 直接解析, 签名者与 chain id 正确
+		decoded := new(gethtypes.Transaction)
+		if err := decoded.UnmarshalBinary(tx.Raw); err != nil {
+			t.Fatalf("%s: %v", txType, err)
+		}
+		sender, err := gethtypes.Sender(gethtypes.LatestSignerForChainID(big.NewInt(1337)), decoded)
+		if err != nil || sender.Hex() != from {
+			t.Fatalf("%s: sender %s, want %s (%v)", txType, sender.Hex(), from, err)
+		}
+		if decoded.Type() != want || decoded.ChainId().Int64() != 1337 || decoded.Nonce() != 7 || decoded.Value().Int64() != 42 || decoded.Gas() != 21000 {
+			t.Fatalf("%s: unexpected transaction type %d chain %s nonce %d value %s", txType, decoded.Type(), decoded.ChainId(), decoded.Nonce(), decoded.Value())
+		}
+		if txType == TxLegacy && !decoded.Protected() {
+			t.Fatalf("legacy transaction is not EIP-155 protected")
+		}
+		if string(tx.Hash) != string(decoded.Hash().Bytes()) {
+			t.Fatalf("%s: hash is not the ethereum transaction hash", txType)
+		}
+
+		content, err := CodecEthereum.Encode(&tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var roundTrip Transaction
+		if err := CodecEthereum.Decode(content, &roundTrip); err != nil {
+			t.Fatal(err)
+		}
+		if roundTrip.From != from || roundTrip.To != to || roundTrip.Value != 42 || roundTrip.Nonce != 7 || roundTrip.Verify() != nil {
+			t.Fatalf("%s: decoded %+v", txType, roundTrip)
+		}
+		if fee, err := roundTrip.Fee(); err != nil || fee != cfg.MaxFee().Int64() {
+			t.Fatalf("%s: fee %d, want %s (%v)", txType, fee, cfg.MaxFee(), err)
+		}
+
+		// NOTE: 跨片交易的以太坊编码保留源 shard 与 Proof, 解码后的交易可以用批次的 Merkle 根验证
+		cst := NewCrossShardTransaction(3, from, to, 42, 7)
+		if err := cst.SignEthereum(cfg, key); err != nil {
+			t.Fatalf("%s: %v", txType, err)
+		}
+		other := NewTransaction(from, to, 1, 8)
+		if err := other.SignEthereum(cfg, key); err != nil {
+			t.Fatalf("%s: %v", txType, err)
+		}
+		tree := NewMerkleTree([][]byte{other.Hash, cst.Hash})
+		if err := cst.SetProof(tree.Proof(1)); err != nil {
+			t.Fatal(err)
+		}
+		content, err = CodecEthereum.Encode(&cst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var crossShard CrossShardTransaction
+		if err := CodecEthereum.Decode(content, &crossShard); err != nil {
+			t.Fatal(err)
+		}
+		if crossShard.ShardID != 3 || string(crossShard.Proof) != string(cst.Proof) || crossShard.From != from || string(crossShard.Raw) != string(cst.Raw) {
+			t.Fatalf("%s: cross-shard transaction decoded as %+v", txType, crossShard)
+		}
+		if err := crossShard.VerifyProof(tree.Root()); err != nil {
+			t.Fatalf("%s: %v", txType, err)
+		}
+		forged := crossShard
+		forged.Hash = other.Hash
+		if err := forged.VerifyProof(tree.Root()); !errors.Is(err, ErrHashMismatch) {
+			t.Fatalf("%s: proof verified for a transaction with a forged hash: %v", txType, err)
+		}
+
+		tampered := tx
+		tampered.Value = 43
+		if err := tampered.Verify(); !errors.Is(err, ErrEthereumMismatch) {
+			t.Fatalf("%s: tampered value verified: %v", txType, err)
+		}
+	}
+
+	native := NewTransaction(from, to, 1, 0)
+	if _, err := CodecEthereum.Encode(&native); !errors.Is(err, ErrNotEthereum) {
+		t.Fatalf("native transaction encoded as ethereum transaction: %v", err)
+	}
+	// NOTE: 账户没有以太坊交易形式, 使用 rlp
+	acc := Account{Address: from, Balance: 1}
+	content, err := CodecEthereum.Encode(&acc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Account
+	if err := CodecRLP.Decode(content, &decoded); err != nil || decoded != acc {
+		t.Fatalf("account decoded as %+v (%v)", decoded, err)
+	}
+	if err := (EthereumConfig{Type: TxDynamic, ChainID: big.NewInt(1), GasLimit: 21000, GasTipCap: big.NewInt(2), GasFeeCap: big.NewInt(1)}).Validate(); err == nil {
+		t.Fatal("fee cap below tip cap accepted")
+	}
+}
@@ -0,0 +1,146 @@
+package types
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+)
+
+var (
+	ErrNoProof      = errors.New("transaction has no merkle proof")
+	ErrInvalidProof = errors.New("merkle proof does not match the root")
+)
+
+// NOTE: 叶子节点与内部节点使用不同的前缀, 防止把内部节点伪造成叶子
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleProof 是叶子到根的路径, Siblings 从叶子所在层开始,
+// 每一层的左右位置由 Index 的对应二进制位决定
+type MerkleProof struct {
+	Index    int      `json:"index"`
+	Siblings [][]byte `json:"siblings"`
+}
+
+// MerkleTree 是以交易 hash 为叶子的二叉 Merkle 树, 某一层节点数为奇数时复制最后一个节点
+type MerkleTree struct {
+	levels [][][]byte
+}
+
+func NewMerkleTree(leaves [][]byte) *MerkleTree {
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = hashLeaf(leaf)
+	}
+	tree := &MerkleTree{levels: [][][]byte{level}}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			next = append(next, hashNode(level[i], right))
+		}
+		tree.levels = append(tree.levels, next)
+		level = next
+	}
+	return tree
+}
+
+// Root 返回树根, 空树返回 nil
+func (t *MerkleTree) Root() []byte {
+	top := t.levels[len(t.levels)-1]
+	if len(top) == 0 {
+		return nil
+	}
+	return top[0]
+}
+
+// Proof 返回第 index 个叶子的证明
+func (t *MerkleTree) Proof(index int) MerkleProof {
+	proof := MerkleProof{Index: index, Siblings: make([][]byte, 0, len(t.levels)-1)}
+	for _, level := range t.levels[:len(t.levels)-1] {
+		sibling := index ^ 1
+		if sibling >= len(level) {
+			sibling = index
+		}
+		proof.Siblings = append(proof.Siblings, level[sibling])
+		index /= 2
+	}
+	return proof
+}
+
+// Verify 检查 leaf 是否通过该路径得到 root
+func (p MerkleProof) Verify(leaf, root []byte) bool {
+	node, index := hashLeaf(leaf), p.Index
+	for _, sibling := range p.Siblings {
+		if index%2 == 0 {
+			node = hashNode(node, sibling)
+		} else {
+			node = hashNode(sibling, node)
+		}
+		index /= 2
+	}
+	return index == 0 && bytes.Equal(node, root)
+}
+
+func hashLeaf(leaf []byte) []byte {
+	hash := sha256.Sum256(append([]byte{merkleLeafPrefix}, leaf...))
+	return hash[:]
+}
+
+func hashNode(left, right []byte) []byte {
+	data := make([]byte, 0, 1+len(left)+len(right))
+	data = append(data, merkleNodePrefix)
+	data = append(data, left...)
+	data = append(data, right...)
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// SetProof 将 proof json 编码后填入 Proof 字段
+func (cst *CrossShardTransaction) SetProof(proof MerkleProof) error {
+	encoded, err := json.Marshal(proof)
+	if err != nil {
+		return err
+	}
+	cst.Proof = encoded
+	return nil
+}
+
+// VerifyProof 重新计算交易 hash, 并检查它是否包含在以 root 为根的批次中
+// NOTE: 以太坊格式的交易 hash 为签名后的以太坊交易 hash, 由 Raw 解码得到
+func (cst *CrossShardTransaction) VerifyProof(root []byte) error {
+	if len(cst.Proof) == 0 {
+		return ErrNoProof
+	}
+	var proof MerkleProof
+	if err := json.Unmarshal(cst.Proof, &proof); err != nil {
+		return err
+	}
+	var hash []byte
+	if len(cst.Raw) > 0 {
+		fields, err := decodeEthereum(cst.Raw)
+		if err != nil {
+			return err
+		}
+		hash = fields.hash
+	} else {
+		computed, err := cst.computeHash()
+		if err != nil {
+			return err
+		}
+		hash = computed
+	}
+	if !bytes.Equal(hash, cst.Hash) {
+		return ErrHashMismatch
+	}
+	if !proof.Verify(hash, root) {
+		return ErrInvalidProof
+	}
+	return nil
+}
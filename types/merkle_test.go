@@ -0,0 +1,49 @@
+package types
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMerkleProof(t *testing.T) {
+	for n := 1; n <= 7; n++ {
+		txs := make([]*CrossShardTransaction, n)
+		leaves := make([][]byte, n)
+		for i := range txs {
+			tx := NewCrossShardTransaction(1, "0xfrom", "0xto", int64(i+1), int64(i))
+			if err := tx.GenerateTransactionHash(); err != nil {
+				t.Fatal(err)
+			}
+			txs[i], leaves[i] = &tx, tx.Hash
+		}
+		tree := NewMerkleTree(leaves)
+		for i, tx := range txs {
+			if err := tx.SetProof(tree.Proof(i)); err != nil {
+				t.Fatal(err)
+			}
+			content, err := tx.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded := &CrossShardTransaction{}
+			if err := decoded.Unmarshal(content); err != nil {
+				t.Fatal(err)
+			}
+			if err := decoded.VerifyProof(tree.Root()); err != nil {
+				t.Fatalf("%d leaves, leaf %d: %v", n, i, err)
+			}
+		}
+
+		other := NewMerkleTree(append(leaves, leaves[0]))
+		if err := txs[0].VerifyProof(other.Root()); !errors.Is(err, ErrInvalidProof) {
+			t.Fatalf("%d leaves: proof verified against a different root: %v", n, err)
+		}
+		txs[0].Value++
+		if err := txs[0].VerifyProof(tree.Root()); !errors.Is(err, ErrHashMismatch) {
+			t.Fatalf("%d leaves: tampered transaction verified: %v", n, err)
+		}
+	}
+	if err := (&CrossShardTransaction{}).VerifyProof(nil); !errors.Is(err, ErrNoProof) {
+		t.Fatalf("expected ErrNoProof, have %v", err)
+	}
+}
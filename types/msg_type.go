@@ -1,8 +1,10 @@
 package types
 
+// NOTE: Codec 为 Content / Transactions / Accounts 中每一项的编码方式, 缺省为 json
 type AccountsMsg struct {
 	Content       [][]byte `json:"content"`
 	AddressNumber int      `json:"number"`
+	Codec         Codec    `json:"codec,omitempty"`
 }
 
 type RequestMsg struct {
@@ -12,4 +14,18 @@ type RequestMsg struct {
 	Transactions           [][]byte `json:"transactions"`
 	CrossShardTransactions [][]byte `json:"cross_shard_transaction"`
 	SequenceID             int64    `json:"sequenceID"`
+	// NOTE: 以批次中交易的 hash 为叶子 (先 Transactions 后 CrossShardTransactions) 的 Merkle 根
+	Root  []byte `json:"root,omitempty"`
+	Codec Codec  `json:"codec,omitempty"`
+}
+
+// MigrationMsg 请求将账户从 FromShard 迁移到 ToShard, Accounts 为迁移时账户的状态
+type MigrationMsg struct {
+	Timestamp     int64    `json:"timestamp"`
+	FromShard     int      `json:"from_shard"`
+	ToShard       int      `json:"to_shard"`
+	Accounts      [][]byte `json:"accounts"`
+	AddressNumber int      `json:"number"`
+	SequenceID    int64    `json:"sequenceID"`
+	Codec         Codec    `json:"codec,omitempty"`
 }
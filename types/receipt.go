@@ -2,27 +2,67 @@ package types
 
 import (
 	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 type Receipt struct {
 	Status bool `json:"status"`
+	// NOTE: 以下字段只在 shard 回调提交结果时填写, 交易中的 Receipt 为空时不参与编码, 保证交易 hash 不变
+	Hash []byte `json:"hash,omitempty"`
+	// NOTE: 提交时间 (UnixNano), 缺省时以生成器收到回调的时间为准
+	CommittedAt int64 `json:"committed_at,omitempty"`
+	BlockHeight int64 `json:"block_height,omitempty"`
+}
+
+// rlpReceipt 是 Receipt 的 RLP 编码形式, 在交易中作为嵌套的列表编码
+type rlpReceipt struct {
+	Status      bool
+	Hash        []byte
+	CommittedAt uint64
+	BlockHeight uint64
+}
+
+func (r *Receipt) toRLP() (rlpReceipt, error) {
+	committedAt, err := toRLPUint("committed_at", r.CommittedAt)
+	if err != nil {
+		return rlpReceipt{}, err
+	}
+	blockHeight, err := toRLPUint("block_height", r.BlockHeight)
+	if err != nil {
+		return rlpReceipt{}, err
+	}
+	return rlpReceipt{Status: r.Status, Hash: r.Hash, CommittedAt: committedAt, BlockHeight: blockHeight}, nil
+}
+
+func (r *Receipt) fromRLP(decoded rlpReceipt) error {
+	committedAt, err := fromRLPUint("committed_at", decoded.CommittedAt)
+	if err != nil {
+		return err
+	}
+	blockHeight, err := fromRLPUint("block_height", decoded.BlockHeight)
+	if err != nil {
+		return err
+	}
+	*r = Receipt{Status: decoded.Status, Hash: rlpBytes(decoded.Hash), CommittedAt: committedAt, BlockHeight: blockHeight}
+	return nil
 }
 
-//func (r *Receipt) RLPEncode() ([]byte, error) {
-//	encoded, err := rlp.EncodeToBytes(r)
-//	if err != nil {
-//		return nil, err
-//	}
-//	return encoded, nil
-//}
-//
-//func (r *Receipt) RLPDecode(content []byte) error {
-//	err := rlp.DecodeBytes(content, r)
-//	if err != nil {
-//		return err
-//	}
-//	return nil
-//}
+func (r *Receipt) RLPEncode() ([]byte, error) {
+	encoded, err := r.toRLP()
+	if err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(&encoded)
+}
+
+func (r *Receipt) RLPDecode(content []byte) error {
+	var decoded rlpReceipt
+	if err := rlp.DecodeBytes(content, &decoded); err != nil {
+		return err
+	}
+	return r.fromRLP(decoded)
+}
 
 func (r *Receipt) Marshal() ([]byte, error) {
 	encoded, err := json.Marshal(r)
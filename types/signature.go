@@ -0,0 +1,40 @@
+package types
+
+import (
+	"crypto/ecdsa"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	ErrUnsigned         = errors.New("transaction is not signed")
+	ErrHashMismatch     = errors.New("transaction hash mismatch")
+	ErrSignerMismatch   = errors.New("signer does not match sender")
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// signHash 使用 secp256k1 对 hash 签名, 返回 [R || S || V] 拆分后的三部分
+func signHash(hash []byte, privateKey *ecdsa.PrivateKey) (uint8, []byte, []byte, error) {
+	sig, err := crypto.Sign(hash, privateKey)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return sig[64], sig[:32], sig[32:64], nil
+}
+
+// recoverAddress 从签名中恢复出签名者的地址
+func recoverAddress(hash []byte, v uint8, r, s []byte) (string, error) {
+	if len(r) != 32 || len(s) != 32 {
+		return "", ErrUnsigned
+	}
+	sig := make([]byte, 65)
+	copy(sig[:32], r)
+	copy(sig[32:64], s)
+	sig[64] = v
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+	return crypto.PubkeyToAddress(*pub).Hex(), nil
+}
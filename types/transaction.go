@@ -1,8 +1,12 @@
 package types
 
 import (
+	"bytes"
+	"crypto/ecdsa"
 	"crypto/sha256"
 	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 type Transaction struct {
@@ -12,6 +16,11 @@ type Transaction struct {
 	Nonce   int64   `json:"nonce"`
 	Receipt Receipt `json:"receipt"`
 	Hash    []byte  `json:"hash"`
+	V       uint8   `json:"v,omitempty"`
+	R       []byte  `json:"r,omitempty"`
+	S       []byte  `json:"s,omitempty"`
+	// NOTE: 以太坊格式 (tx_type 为 legacy / dynamic) 时为签名后的以太坊交易, Hash 为其 hash
+	Raw []byte `json:"raw,omitempty"`
 }
 
 func NewTransaction(from, to string, value, nonce int64) Transaction {
@@ -25,6 +34,15 @@ func NewTransaction(from, to string, value, nonce int64) Transaction {
 }
 
 func (t *Transaction) GenerateTransactionHash() error {
+	hash, err := t.computeHash()
+	if err != nil {
+		return err
+	}
+	t.Hash = hash
+	return nil
+}
+
+func (t *Transaction) computeHash() ([]byte, error) {
 	txData := Transaction{
 		From:  t.From,
 		To:    t.To,
@@ -34,29 +52,115 @@ func (t *Transaction) GenerateTransactionHash() error {
 
 	data, err := json.Marshal(txData)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	hash := sha256.Sum256(data)
-	t.Hash = hash[:]
+	return hash[:], nil
+}
+
+// Sign 使用发送方私钥对交易 hash 签名, 填充 V/R/S
+func (t *Transaction) Sign(privateKey *ecdsa.PrivateKey) error {
+	if err := t.GenerateTransactionHash(); err != nil {
+		return err
+	}
+	v, r, s, err := signHash(t.Hash, privateKey)
+	if err != nil {
+		return err
+	}
+	t.V, t.R, t.S = v, r, s
 	return nil
 }
 
-//func (t *Transaction) RLPEncode() ([]byte, error) {
-//	encoded, err := rlp.EncodeToBytes(t)
-//	if err != nil {
-//		return nil, err
-//	}
-//	return encoded, nil
-//}
-//
-//func (t *Transaction) RLPDecode(content []byte) error {
-//	err := rlp.DecodeBytes(content, t)
-//	if err != nil {
-//		return err
-//	}
-//	return nil
-//}
+// Verify 重新计算 hash 并从签名中恢复签名者, 检查其是否为 From
+func (t *Transaction) Verify() error {
+	if len(t.Raw) > 0 {
+		return verifyEthereum(t.Raw, t.Hash, t.From, t.To, t.Value, t.Nonce)
+	}
+	hash, err := t.computeHash()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(hash, t.Hash) {
+		return ErrHashMismatch
+	}
+	signer, err := recoverAddress(hash, t.V, t.R, t.S)
+	if err != nil {
+		return err
+	}
+	if signer != t.From {
+		return ErrSignerMismatch
+	}
+	return nil
+}
+
+// rlpTransaction 是 Transaction 的 RLP 编码形式, 字段顺序即编码顺序
+type rlpTransaction struct {
+	From    string
+	To      string
+	Value   uint64
+	Nonce   uint64
+	Receipt rlpReceipt
+	Hash    []byte
+	V       uint8
+	R       []byte
+	S       []byte
+	Raw     []byte `rlp:"optional"`
+}
+
+func (t *Transaction) RLPEncode() ([]byte, error) {
+	value, err := toRLPUint("value", t.Value)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := toRLPUint("nonce", t.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	receipt, err := t.Receipt.toRLP()
+	if err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(&rlpTransaction{
+		From:    t.From,
+		To:      t.To,
+		Value:   value,
+		Nonce:   nonce,
+		Receipt: receipt,
+		Hash:    t.Hash,
+		V:       t.V,
+		R:       t.R,
+		S:       t.S,
+		Raw:     t.Raw,
+	})
+}
+
+func (t *Transaction) RLPDecode(content []byte) error {
+	var decoded rlpTransaction
+	if err := rlp.DecodeBytes(content, &decoded); err != nil {
+		return err
+	}
+	value, err := fromRLPUint("value", decoded.Value)
+	if err != nil {
+		return err
+	}
+	nonce, err := fromRLPUint("nonce", decoded.Nonce)
+	if err != nil {
+		return err
+	}
+	*t = Transaction{
+		From:  decoded.From,
+		To:    decoded.To,
+		Value: value,
+		Nonce: nonce,
+		Hash:  rlpBytes(decoded.Hash),
+		V:     decoded.V,
+		R:     rlpBytes(decoded.R),
+		S:     rlpBytes(decoded.S),
+		Raw:   rlpBytes(decoded.Raw),
+	}
+	return t.Receipt.fromRLP(decoded.Receipt)
+}
 
 func (t *Transaction) Marshal() ([]byte, error) {
 	encoded, err := json.Marshal(t)